@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 )
 
@@ -83,7 +84,7 @@ func dirTreeRecur(path string, includeFiles bool, offset string, out io.Writer)
 			return err
 		}
 		if fsItem.IsDir() {
-			err = dirTreeRecur(path+string(os.PathSeparator)+fsItem.ToString(), includeFiles, nextOff, out)
+			err = dirTreeRecur(filepath.Join(path, fsItem.ToString()), includeFiles, nextOff, out)
 		}
 
 		if err != nil {
@@ -94,13 +95,24 @@ func dirTreeRecur(path string, includeFiles bool, offset string, out io.Writer)
 	return err
 }
 
-func dirTree(out io.Writer, path string, printFiles bool) error {
+func resolveRoot(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+
 	currDir, err := os.Getwd()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	dir := currDir + string(os.PathSeparator) + path
+	return filepath.Join(currDir, filepath.FromSlash(path)), nil
+}
+
+func dirTree(out io.Writer, path string, printFiles bool) error {
+	dir, err := resolveRoot(path)
+	if err != nil {
+		return err
+	}
 
 	err = dirTreeRecur(dir, printFiles, "", out)
 
@@ -109,13 +121,29 @@ func dirTree(out io.Writer, path string, printFiles bool) error {
 
 func main() {
 	out := os.Stdout
-	if !(len(os.Args) == 2 || len(os.Args) == 3) {
-		panic("usage go run main.go . [-f]")
+	paths := make([]string, 0, len(os.Args))
+	printFiles := false
+	for _, arg := range os.Args[1:] {
+		if arg == "-f" {
+			printFiles = true
+		} else {
+			paths = append(paths, arg)
+		}
 	}
-	path := os.Args[1]
-	printFiles := len(os.Args) == 3 && os.Args[2] == "-f"
-	err := dirTree(out, path, printFiles)
-	if err != nil {
-		panic(err.Error())
+	if len(paths) == 0 {
+		panic("usage go run main.go . [more paths...] [-f]")
+	}
+
+	for i, path := range paths {
+		if len(paths) > 1 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintln(out, path)
+		}
+		err := dirTree(out, path, printFiles)
+		if err != nil {
+			panic(err.Error())
+		}
 	}
 }
@@ -0,0 +1,3 @@
+module hw1_tree
+
+go 1.20
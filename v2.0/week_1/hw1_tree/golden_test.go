@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*
+	Golden-тесты: каждый подкаталог golden/ - отдельный кейс
+	* flags - аргументы командной строки, пути считаются от каталога кейса
+	* expected.golden - ожидаемый вывод
+	* fixture/ - собственное дерево кейса, если общего testdata не хватает
+
+	go test -run TestGolden -update перезаписывает expected.golden текущим выводом
+*/
+
+var update = flag.Bool("update", false, "rewrite golden/*/expected.golden with the current output")
+
+const goldenDir = "golden"
+
+func runGoldenCase(t *testing.T, caseDir string) []byte {
+	flagsRaw, err := os.ReadFile(filepath.Join(caseDir, "flags"))
+	if err != nil {
+		t.Fatalf("cant read flags: %v", err)
+	}
+
+	args, err := parseArgs(strings.Fields(string(flagsRaw)))
+	if err != nil {
+		t.Fatalf("bad flags: %v", err)
+	}
+
+	currDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("cant get working directory: %v", err)
+	}
+	err = os.Chdir(caseDir)
+	if err != nil {
+		t.Fatalf("cant enter case directory: %v", err)
+	}
+	defer func() {
+		err := os.Chdir(currDir)
+		if err != nil {
+			t.Fatalf("cant restore working directory: %v", err)
+		}
+	}()
+
+	out := new(bytes.Buffer)
+	err = renderTrees(out, args)
+	if err != nil {
+		t.Fatalf("test for OK Failed - error: %v", err)
+	}
+
+	return out.Bytes()
+}
+
+func TestGolden(t *testing.T) {
+	cases, err := os.ReadDir(goldenDir)
+	if err != nil {
+		t.Fatalf("cant read golden cases: %v", err)
+	}
+
+	for _, goldenCase := range cases {
+		if !goldenCase.IsDir() {
+			continue
+		}
+
+		caseDir := filepath.Join(goldenDir, goldenCase.Name())
+		t.Run(goldenCase.Name(), func(t *testing.T) {
+			result := runGoldenCase(t, caseDir)
+			expectedPath := filepath.Join(caseDir, "expected.golden")
+
+			if *update {
+				err := os.WriteFile(expectedPath, result, 0644)
+				if err != nil {
+					t.Fatalf("cant update golden file: %v", err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(expectedPath)
+			if err != nil {
+				t.Fatalf("cant read golden file, run with -update to create it: %v", err)
+			}
+			if !bytes.Equal(result, expected) {
+				t.Errorf("results not match\nGot:\n%s\nExpected:\n%s", result, expected)
+			}
+		})
+	}
+}
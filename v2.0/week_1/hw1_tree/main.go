@@ -4,103 +4,211 @@ import (
 	"fmt"
 	"io"
 	"os"
-	pathLib "path"
-	"sort"
+	"path/filepath"
 	"strconv"
 	"strings"
+
+	"hw1_tree/hw1_tree/tree"
 )
 
-func filterDirEntries(dirEntries []os.DirEntry, includeFiles bool) []os.DirEntry {
-	pointer := 0
-	for i := range dirEntries {
-		if !includeFiles && !dirEntries[i].IsDir() || strings.Contains(dirEntries[i].Name(), ".DS_Store") {
-			continue
-		}
+const (
+	formatText     = "text"
+	formatMarkdown = "markdown"
+
+	markdownStyleList = "list"
+	markdownStyleCode = "code"
+
+	sortByName    = "name"
+	sortDirsFirst = "dirsfirst"
 
-		dirEntries[pointer] = dirEntries[i]
-		pointer++
+	commandDu = "du"
+)
+
+func dirTree(out io.Writer, path string, printFiles bool) error {
+	return tree.Render(out, path, tree.Options{Files: printFiles})
+}
+
+type cliArgs struct {
+	paths []string
+	opts  tree.Options
+	du    bool
+	top   int
+	// markdownStyle is the style of the markdown output, empty for text.
+	markdownStyle string
+}
+
+func parseRenderer(format, markdownStyle string) (tree.Renderer, error) {
+	switch {
+	case format == formatText:
+		return tree.Text, nil
+	case format == formatMarkdown && markdownStyle == markdownStyleList:
+		return tree.MarkdownList, nil
+	case format == formatMarkdown && markdownStyle == markdownStyleCode:
+		return tree.MarkdownCode, nil
+	case format == formatMarkdown:
+		return nil, fmt.Errorf("unknown markdown style %q", markdownStyle)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
 	}
+}
 
-	return dirEntries[:pointer]
+func parseLess(order string) (tree.Less, error) {
+	switch order {
+	case sortByName:
+		return tree.ByName, nil
+	case sortDirsFirst:
+		return tree.DirsFirst, nil
+	default:
+		return nil, fmt.Errorf("unknown sort order %q", order)
+	}
 }
 
-func getFileSize(file os.DirEntry) (string, error) {
-	fileInfo, err := file.Info()
+func parseArgs(args []string) (cliArgs, error) {
+	parsed := cliArgs{}
+	if len(args) > 0 && args[0] == commandDu {
+		parsed.du = true
+		parsed.opts.Files = true
+		args = args[1:]
+	}
+
+	format, markdownStyle, order := formatText, markdownStyleList, sortByName
+	excludes := make([]string, 0)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var err error
+		switch {
+		case parsed.du && arg == "--top":
+			if i+1 == len(args) {
+				return parsed, fmt.Errorf("bad top: missing value after %q", arg)
+			}
+			i++
+			parsed.top, err = strconv.Atoi(args[i])
+			if err != nil || parsed.top < 0 {
+				return parsed, fmt.Errorf("bad top %q", args[i])
+			}
+		case parsed.du && strings.HasPrefix(arg, "--top="):
+			parsed.top, err = strconv.Atoi(strings.TrimPrefix(arg, "--top="))
+			if err != nil || parsed.top < 0 {
+				return parsed, fmt.Errorf("bad top %q", arg)
+			}
+		case arg == "-f":
+			parsed.opts.Files = true
+		case strings.HasPrefix(arg, "--format="):
+			format = strings.TrimPrefix(arg, "--format=")
+		case strings.HasPrefix(arg, "--markdown-style="):
+			markdownStyle = strings.TrimPrefix(arg, "--markdown-style=")
+		case strings.HasPrefix(arg, "--sort="):
+			order = strings.TrimPrefix(arg, "--sort=")
+		case strings.HasPrefix(arg, "--exclude="):
+			excludes = append(excludes, strings.TrimPrefix(arg, "--exclude="))
+		case strings.HasPrefix(arg, "--depth="):
+			parsed.opts.MaxDepth, err = strconv.Atoi(strings.TrimPrefix(arg, "--depth="))
+			if err != nil || parsed.opts.MaxDepth < 0 {
+				return parsed, fmt.Errorf("bad depth %q", arg)
+			}
+		default:
+			parsed.paths = append(parsed.paths, arg)
+		}
+	}
+
+	if len(parsed.paths) == 0 {
+		return parsed, fmt.Errorf("expected at least one path")
+	}
+
+	if len(excludes) > 0 {
+		parsed.opts.Filters = append(parsed.opts.Filters, tree.ExcludeNames(excludes...))
+	}
+
+	var err error
+	parsed.opts.Renderer, err = parseRenderer(format, markdownStyle)
 	if err != nil {
-		return "", err
+		return parsed, err
+	}
+	if format == formatMarkdown {
+		parsed.markdownStyle = markdownStyle
 	}
 
-	fileSize := fileInfo.Size()
-	if fileSize == 0 {
-		return "(empty)", nil
+	parsed.opts.Less, err = parseLess(order)
+	if err != nil {
+		return parsed, err
 	}
 
-	return "(" + strconv.FormatInt(fileSize, 10) + "b)", err
+	return parsed, nil
 }
 
-func dirTreeRecur(prefix string, out io.Writer, path string, printFiles bool) error {
-	dirEntries, err := os.ReadDir(path)
+func renderUsage(out io.Writer, args cliArgs, path string) error {
+	walkOpts := args.opts
+	walkOpts.MaxDepth = 0
+	root, err := tree.Walk(path, walkOpts)
 	if err != nil {
 		return err
 	}
 
-	dirEntries = filterDirEntries(dirEntries, printFiles)
-	sort.Slice(dirEntries, func(i, j int) bool {
-		return dirEntries[i].Name() < dirEntries[j].Name()
-	})
+	usages, total := tree.TopUsage(root, args.top, args.opts.MaxDepth)
+	return tree.RenderUsage(out, usages, total)
+}
 
-	var dirEntryBeginning, nextPrefix string
-	for i, dirEntry := range dirEntries {
-		if i+1 == len(dirEntries) {
-			dirEntryBeginning = prefix + "└───"
-			if dirEntry.IsDir() {
-				nextPrefix = prefix + "\t"
-			}
-		} else {
-			dirEntryBeginning = prefix + "├───"
-			if dirEntry.IsDir() {
-				nextPrefix = prefix + "│\t"
-			}
+func renderRoot(out io.Writer, args cliArgs, path string) error {
+	if args.du {
+		return renderUsage(out, args, path)
+	}
+	return tree.Render(out, path, args.opts)
+}
+
+// renderTitled prints one of several roots under its title. Markdown titles
+// are a heading or, for the list style, a list item the links of the root
+// continue from, the rest gets the argument as a plain line.
+func renderTitled(out io.Writer, args cliArgs, path string) error {
+	switch {
+	case args.du || args.markdownStyle == "":
+		_, err := fmt.Fprintln(out, path)
+		if err != nil {
+			return err
 		}
+		return renderRoot(out, args, path)
+	case args.markdownStyle == markdownStyleList:
+		args.opts.Renderer = tree.TitledMarkdownList(filepath.ToSlash(path))
+	default:
+		args.opts.Renderer = tree.TitledMarkdownCode(path)
+	}
+	return renderRoot(out, args, path)
+}
 
-		if !dirEntry.IsDir() {
-			fileSize, err := getFileSize(dirEntry)
-			if err != nil {
-				return err
-			}
-			_, err = fmt.Fprintln(out, dirEntryBeginning+dirEntry.Name()+" "+fileSize)
+// renderTrees prints every root as its own tree. A single root keeps the classic
+// output, several roots are titled with the argument and separated by an empty
+// line, a markdown list continues as one list.
+func renderTrees(out io.Writer, args cliArgs) error {
+	if len(args.paths) == 1 {
+		return renderRoot(out, args, args.paths[0])
+	}
+
+	for i, path := range args.paths {
+		if i > 0 && (args.du || args.markdownStyle != markdownStyleList) {
+			_, err := fmt.Fprintln(out)
 			if err != nil {
 				return err
 			}
-			continue
 		}
 
-		_, err = fmt.Fprintln(out, dirEntryBeginning+dirEntry.Name())
-		if err != nil {
-			return err
-		}
-
-		err = dirTreeRecur(nextPrefix, out, pathLib.Join(path, dirEntry.Name()), printFiles)
+		err := renderTitled(out, args, path)
 		if err != nil {
 			return err
 		}
 	}
 
-	return err
-}
-
-func dirTree(out io.Writer, path string, printFiles bool) error {
-	return dirTreeRecur("", out, path, printFiles)
+	return nil
 }
 
 func main() {
 	out := os.Stdout
-	if !(len(os.Args) == 2 || len(os.Args) == 3) {
-		panic("usage go run main.go . [-f]")
+	args, err := parseArgs(os.Args[1:])
+	if err != nil {
+		panic("usage go run main.go . [more paths...] [-f] [--depth=N] [--exclude=pattern] [--sort=name|dirsfirst] " +
+			"[--format=text|markdown] [--markdown-style=list|code]\n" +
+			"   or go run main.go du [--top N] [--depth=N] [--exclude=pattern] . [more paths...]: " + err.Error())
 	}
-	path := os.Args[1]
-	printFiles := len(os.Args) == 3 && os.Args[2] == "-f"
-	err := dirTree(out, path, printFiles)
+
+	err = renderTrees(out, args)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -73,3 +75,34 @@ func TestTreeDir(t *testing.T) {
 		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDirResult)
 	}
 }
+
+func TestTreeAbsolutePath(t *testing.T) {
+	absPath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatalf("cant resolve testdata: %v", err)
+	}
+	out := new(bytes.Buffer)
+	err = dirTree(out, absPath, false)
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testDirResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDirResult)
+	}
+}
+
+func TestParseArgsBadTop(t *testing.T) {
+	cases := [][]string{
+		{"du", ".", "--top"},
+		{"du", "--top", "-1", "."},
+		{"du", "--top=x", "."},
+	}
+
+	for _, args := range cases {
+		_, err := parseArgs(args)
+		if err == nil || !strings.HasPrefix(err.Error(), "bad top") {
+			t.Errorf("expected bad top for %v, got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package tree
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const usageBarWidth = 20
+
+// Usage is the aggregated size of one directory.
+type Usage struct {
+	RelPath string
+	Depth   int
+	Size    int64
+}
+
+func usageRecur(node *Node, maxDepth int, usages *[]Usage) int64 {
+	if !node.IsDir {
+		return node.Size
+	}
+
+	var size int64
+	for _, child := range node.Children {
+		size += usageRecur(child, maxDepth, usages)
+	}
+
+	if node.Depth > 0 && (maxDepth <= 0 || node.Depth <= maxDepth) {
+		*usages = append(*usages, Usage{RelPath: node.RelPath, Depth: node.Depth, Size: size})
+	}
+
+	return size
+}
+
+// TopUsage aggregates file sizes per directory of a tree walked with Files
+// and returns the n heaviest directories below root together with the root total.
+// Only directories up to maxDepth levels below root are ranked, but their sizes
+// always include the whole subtree. n <= 0 and maxDepth <= 0 mean no limit.
+func TopUsage(root *Node, n, maxDepth int) ([]Usage, int64) {
+	usages := make([]Usage, 0)
+	total := usageRecur(root, maxDepth, &usages)
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Size != usages[j].Size {
+			return usages[i].Size > usages[j].Size
+		}
+		return usages[i].RelPath < usages[j].RelPath
+	})
+
+	if n > 0 && n < len(usages) {
+		usages = usages[:n]
+	}
+
+	return usages, total
+}
+
+func usageBar(size, total int64) string {
+	filled := 0
+	if total > 0 {
+		filled = int(size * usageBarWidth / total)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", usageBarWidth-filled)
+}
+
+// RenderUsage prints usages as "percent bar size path" lines, percentages relative to total.
+func RenderUsage(out io.Writer, usages []Usage, total int64) error {
+	for _, usage := range usages {
+		var percent float64
+		if total > 0 {
+			percent = float64(usage.Size) * 100 / float64(total)
+		}
+
+		_, err := fmt.Fprintf(out, "%6.2f%% %s %10s %s\n",
+			percent, usageBar(usage.Size, total), strconv.FormatInt(usage.Size, 10)+"b", usage.RelPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package tree
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Text is the classic output of the tree utility.
+	Text Renderer = RendererFunc(renderText)
+	// MarkdownList renders a nested bullet list with links relative to the root.
+	MarkdownList Renderer = RendererFunc(renderMarkdownList)
+	// MarkdownCode renders the Text output wrapped into a fenced code block.
+	MarkdownCode Renderer = RendererFunc(renderMarkdownCode)
+)
+
+// TitledMarkdownList is MarkdownList nested under a list item for the root, the
+// links are prefixed with root, the slash separated path the tree was walked from.
+// Several of them make one list with working links.
+func TitledMarkdownList(root string) Renderer {
+	base := strings.TrimSuffix(path.Clean(root), "/")
+	return RendererFunc(func(out io.Writer, node *Node) error {
+		_, err := fmt.Fprintln(out, "- ["+markdownEscaper.Replace(base)+"/]("+markdownLink(base)+"/)")
+		if err != nil {
+			return err
+		}
+		return markdownListRecur("  ", base+"/", out, node)
+	})
+}
+
+// TitledMarkdownCode is MarkdownCode under a heading with the root.
+func TitledMarkdownCode(root string) Renderer {
+	return RendererFunc(func(out io.Writer, node *Node) error {
+		_, err := fmt.Fprintln(out, "## "+markdownEscaper.Replace(root)+"\n")
+		if err != nil {
+			return err
+		}
+		return renderMarkdownCode(out, node)
+	})
+}
+
+// FormatSize prints a file size the way the tree output shows it: "(empty)" or "(<n>b)".
+func FormatSize(size int64) string {
+	if size == 0 {
+		return "(empty)"
+	}
+
+	return "(" + strconv.FormatInt(size, 10) + "b)"
+}
+
+func textRecur(prefix string, out io.Writer, node *Node) error {
+	var dirEntryBeginning, nextPrefix string
+	for i, child := range node.Children {
+		if i+1 == len(node.Children) {
+			dirEntryBeginning = prefix + "└───"
+			nextPrefix = prefix + "\t"
+		} else {
+			dirEntryBeginning = prefix + "├───"
+			nextPrefix = prefix + "│\t"
+		}
+
+		if !child.IsDir {
+			_, err := fmt.Fprintln(out, dirEntryBeginning+child.Name+" "+FormatSize(child.Size))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, err := fmt.Fprintln(out, dirEntryBeginning+child.Name)
+		if err != nil {
+			return err
+		}
+
+		err = textRecur(nextPrefix, out, child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func renderText(out io.Writer, root *Node) error {
+	return textRecur("", out, root)
+}
+
+func markdownLink(relPath string) string {
+	segments := strings.Split(relPath, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+// markdownEscaper keeps the characters of file names from being read as markdown syntax.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+)
+
+func markdownListRecur(indent, base string, out io.Writer, node *Node) error {
+	for _, child := range node.Children {
+		line := indent + "- [" + markdownEscaper.Replace(child.Name)
+		if child.IsDir {
+			line += "/](" + markdownLink(base+child.RelPath) + "/)"
+		} else {
+			line += "](" + markdownLink(base+child.RelPath) + ") " + FormatSize(child.Size)
+		}
+
+		_, err := fmt.Fprintln(out, line)
+		if err != nil {
+			return err
+		}
+
+		err = markdownListRecur(indent+"  ", base, out, child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func renderMarkdownList(out io.Writer, root *Node) error {
+	return markdownListRecur("", "", out, root)
+}
+
+func renderMarkdownCode(out io.Writer, root *Node) error {
+	_, err := fmt.Fprintln(out, "```")
+	if err != nil {
+		return err
+	}
+
+	err = renderText(out, root)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, "```")
+	return err
+}
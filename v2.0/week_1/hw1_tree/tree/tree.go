@@ -0,0 +1,204 @@
+// Package tree walks a directory and renders it the way the hw1 tree utility does.
+package tree
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Node is a single directory entry found by Walk.
+type Node struct {
+	Name string
+	// Path is the location of the entry on disk.
+	Path string
+	// RelPath is the slash separated path of the entry relative to the walked root.
+	RelPath  string
+	IsDir    bool
+	Size     int64
+	Depth    int
+	Children []*Node
+}
+
+// Filter reports whether a node should be kept. Rejected directories are not descended into.
+type Filter func(node *Node) bool
+
+// Less orders the entries of one directory level.
+type Less func(a, b *Node) bool
+
+// Renderer prints a walked tree.
+type Renderer interface {
+	Render(out io.Writer, root *Node) error
+}
+
+// RendererFunc adapts an ordinary function to the Renderer interface.
+type RendererFunc func(out io.Writer, root *Node) error
+
+func (f RendererFunc) Render(out io.Writer, root *Node) error {
+	return f(out, root)
+}
+
+// Options configure Walk and Render. The zero value lists directories only,
+// without depth limit, sorted by name and rendered as Text.
+type Options struct {
+	Files bool
+	// MaxDepth limits the number of levels below the root, 0 means unlimited.
+	MaxDepth int
+	Filters  []Filter
+	Less     Less
+	Renderer Renderer
+}
+
+// ByName is the default order of the classic tree output.
+func ByName(a, b *Node) bool {
+	return a.Name < b.Name
+}
+
+// DirsFirst lists directories before files, both groups ordered by name.
+func DirsFirst(a, b *Node) bool {
+	if a.IsDir != b.IsDir {
+		return a.IsDir
+	}
+	return a.Name < b.Name
+}
+
+// ExcludeNames drops entries whose name matches any of the filepath.Match patterns.
+func ExcludeNames(patterns ...string) Filter {
+	return func(node *Node) bool {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, node.Name); matched {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ResolveRoot turns a command line argument into a walkable directory path:
+// absolute paths are kept as is, relative ones are resolved against the working directory.
+func ResolveRoot(root string) (string, error) {
+	if filepath.IsAbs(root) {
+		return filepath.Clean(root), nil
+	}
+
+	currDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(currDir, filepath.FromSlash(root)), nil
+}
+
+func (o Options) keep(node *Node) bool {
+	if !o.Files && !node.IsDir || strings.Contains(node.Name, ".DS_Store") {
+		return false
+	}
+
+	for _, filter := range o.Filters {
+		if !filter(node) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (o Options) less() Less {
+	if o.Less == nil {
+		return ByName
+	}
+	return o.Less
+}
+
+func walkRecur(parent *Node, opts Options) error {
+	dirEntries, err := os.ReadDir(parent.Path)
+	if err != nil {
+		return err
+	}
+
+	children := make([]*Node, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		node := &Node{
+			Name:    dirEntry.Name(),
+			Path:    filepath.Join(parent.Path, dirEntry.Name()),
+			RelPath: path.Join(parent.RelPath, dirEntry.Name()),
+			IsDir:   dirEntry.IsDir(),
+			Depth:   parent.Depth + 1,
+		}
+		if !opts.keep(node) {
+			continue
+		}
+
+		if !node.IsDir {
+			fileInfo, err := dirEntry.Info()
+			if err != nil {
+				return err
+			}
+			node.Size = fileInfo.Size()
+		}
+
+		children = append(children, node)
+	}
+
+	less := opts.less()
+	sort.Slice(children, func(i, j int) bool {
+		return less(children[i], children[j])
+	})
+	parent.Children = children
+
+	if opts.MaxDepth > 0 && parent.Depth+1 >= opts.MaxDepth {
+		return nil
+	}
+
+	for _, child := range children {
+		if !child.IsDir {
+			continue
+		}
+
+		err = walkRecur(child, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Walk reads the directory root recursively and returns it as a tree of nodes.
+func Walk(root string, opts Options) (*Node, error) {
+	rootPath, err := ResolveRoot(root)
+	if err != nil {
+		return nil, err
+	}
+
+	rootNode := &Node{
+		Name:  filepath.Base(rootPath),
+		Path:  rootPath,
+		IsDir: true,
+	}
+
+	err = walkRecur(rootNode, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return rootNode, nil
+}
+
+// Render walks root and prints it with opts.Renderer, Text by default.
+func Render(out io.Writer, root string, opts Options) error {
+	rootNode, err := Walk(root, opts)
+	if err != nil {
+		return err
+	}
+
+	renderer := opts.Renderer
+	if renderer == nil {
+		renderer = Text
+	}
+
+	return renderer.Render(out, rootNode)
+}
@@ -0,0 +1,94 @@
+package tree
+
+import (
+	"bytes"
+	"testing"
+)
+
+const testDepthResult = `├───a_lorem
+│	├───dolor.txt (empty)
+│	├───gopher.png (70372b)
+│	└───ipsum
+└───empty.txt (empty)
+`
+
+func TestRenderDepthAndFilters(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := Render(out, "../testdata/static", Options{
+		Files:    true,
+		MaxDepth: 2,
+		Filters:  []Filter{ExcludeNames("z_*", "css", "html", "js")},
+	})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	result := out.String()
+	if result != testDepthResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDepthResult)
+	}
+}
+
+const testDirsFirstResult = `├───lorem
+│	├───ipsum
+│	│	└───gopher.png (70372b)
+│	├───dolor.txt (empty)
+│	└───gopher.png (70372b)
+└───empty.txt (empty)
+`
+
+func TestRenderDirsFirst(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := Render(out, "../testdata/zline", Options{Files: true, Less: DirsFirst})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	result := out.String()
+	if result != testDirsFirstResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDirsFirstResult)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root, err := Walk("../testdata/project", Options{Files: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if root.Name != "project" || !root.IsDir || len(root.Children) != 2 {
+		t.Fatalf("bad root node: %+v", root)
+	}
+
+	file := root.Children[0]
+	if file.Name != "file.txt" || file.RelPath != "file.txt" || file.Size != 19 || file.Depth != 1 {
+		t.Errorf("bad file node: %+v", file)
+	}
+}
+
+func TestWalkMissingRoot(t *testing.T) {
+	_, err := Walk("../testdata/missing", Options{})
+	if err == nil {
+		t.Errorf("expected error for missing root")
+	}
+}
+
+func TestTopUsage(t *testing.T) {
+	root, err := Walk("../testdata/static", Options{Files: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	usages, total := TopUsage(root, 2, 0)
+	if total != 140744*2+28+57+10 {
+		t.Errorf("bad total: %d", total)
+	}
+	if len(usages) != 2 || usages[0].RelPath != "a_lorem" || usages[0].Size != 140744 || usages[1].RelPath != "z_lorem" {
+		t.Errorf("bad top usages: %+v", usages)
+	}
+
+	usages, _ = TopUsage(root, 0, 1)
+	for _, usage := range usages {
+		if usage.Depth != 1 {
+			t.Errorf("usage deeper than max depth: %+v", usage)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+module hw2_signer
+
+go 1.20
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	gopkg.in/yaml.v3 v3.0.1
+)
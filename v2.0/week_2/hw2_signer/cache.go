@@ -0,0 +1,168 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+// CacheStats count how SignCache served the lookups.
+type CacheStats struct {
+	Hits uint64
+	// Shared lookups waited for the same value computed by another caller.
+	Shared    uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+type cacheEntry struct {
+	key  string
+	hash string
+}
+
+type inflightSign struct {
+	done chan struct{}
+	hash string
+	err  error
+}
+
+// SignCache is an LRU cache of signatures shared by any number of signers.
+// Concurrent lookups of a missing key wait for a single computation.
+type SignCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*inflightSign
+	stats    CacheStats
+}
+
+func NewSignCache(capacity int) *SignCache {
+	if capacity <= 0 {
+		capacity = MaxInputDataLen
+	}
+	return &SignCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]*inflightSign),
+	}
+}
+
+func (c *SignCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+func (c *SignCache) store(key, hash string) {
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).hash = hash
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, hash: hash})
+	if c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Do returns the cached value of key or computes it with sign. Errors are not cached.
+func (c *SignCache) Do(ctx context.Context, key string, sign func() (string, error)) (string, error) {
+	for {
+		c.mu.Lock()
+		if elem, ok := c.entries[key]; ok {
+			c.lru.MoveToFront(elem)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return elem.Value.(*cacheEntry).hash, nil
+		}
+
+		if call, ok := c.inflight[key]; ok {
+			c.stats.Shared++
+			c.mu.Unlock()
+
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+
+			// the caller computing the value gave up, try again on our own
+			if isContextErr(call.err) && ctx.Err() == nil {
+				continue
+			}
+			return call.hash, call.err
+		}
+
+		call := &inflightSign{done: make(chan struct{})}
+		c.inflight[key] = call
+		c.stats.Misses++
+		c.mu.Unlock()
+
+		c.compute(key, call, sign)
+		return call.hash, call.err
+	}
+}
+
+// compute fills call with the result of sign. A panic of sign becomes a
+// PanicError of call, the call is finished anyway, so the lookups of key
+// waiting for it don't hang.
+func (c *SignCache) compute(key string, call *inflightSign, sign func() (string, error)) {
+	defer c.finish(key, call)
+	defer catchPanic(nil, &call.err)
+	call.hash, call.err = sign()
+}
+
+func (c *SignCache) finish(key string, call *inflightSign) {
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.store(key, call.hash)
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+type cachedSigner struct {
+	Signer
+	cache *SignCache
+}
+
+// cacheKeyer is implemented by signers whose result depends on more than the
+// algorithm name and the data, like a salt or a secret key.
+type cacheKeyer interface {
+	cacheKey() string
+}
+
+func signerCacheKey(s Signer) string {
+	if keyer, ok := s.(cacheKeyer); ok {
+		return keyer.cacheKey()
+	}
+	return s.Name()
+}
+
+func (s cachedSigner) Sign(ctx context.Context, data string) (string, error) {
+	key := signerCacheKey(s.Signer) + "\x00" + data
+	return s.cache.Do(ctx, key, func() (string, error) {
+		return s.Signer.Sign(ctx, data)
+	})
+}
+
+// Cached looks up the signatures of s in cache first, keyed by algorithm, salt and data.
+func Cached(s Signer, cache *SignCache) Signer {
+	return cachedSigner{Signer: s, cache: cache}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingSigner считает вызовы и может падать, пока fails > 0
+type countingSigner struct {
+	Signer
+	calls uint32
+	fails int32
+	delay time.Duration
+}
+
+var errFlaky = errors.New("flaky signer")
+
+func (s *countingSigner) Sign(ctx context.Context, data string) (string, error) {
+	atomic.AddUint32(&s.calls, 1)
+	time.Sleep(s.delay)
+	if atomic.AddInt32(&s.fails, -1) >= 0 {
+		return "", errFlaky
+	}
+	return s.Signer.Sign(ctx, data)
+}
+
+func TestSignCacheSingleflight(t *testing.T) {
+	cache := NewSignCache(10)
+	signer := &countingSigner{Signer: Crc32Signer{}, delay: 20 * time.Millisecond}
+	cached := Cached(signer, cache)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hash, err := cached.Sign(context.Background(), "0")
+			if err != nil || hash != "4108050209" {
+				t.Errorf("unexpected result: %v, %v", hash, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if signer.calls != 1 {
+		t.Errorf("expected 1 computation, got %d", signer.calls)
+	}
+	stats := cache.Stats()
+	if stats.Misses != 1 || stats.Hits+stats.Shared != 9 || stats.Size != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSignCacheLRU(t *testing.T) {
+	cache := NewSignCache(2)
+	signer := &countingSigner{Signer: Crc32Signer{}}
+	cached := Cached(signer, cache)
+
+	for _, data := range []string{"a", "b", "a", "c", "b"} {
+		if _, err := cached.Sign(context.Background(), data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// b вытеснен при добавлении c, так как a был использован позже
+	stats := cache.Stats()
+	if signer.calls != 4 || stats.Hits != 1 || stats.Misses != 4 || stats.Evictions != 2 || stats.Size != 2 {
+		t.Errorf("unexpected stats: calls %d, %+v", signer.calls, stats)
+	}
+}
+
+func TestSignCacheKeys(t *testing.T) {
+	cache := NewSignCache(10)
+	signers := []Signer{
+		Cached(Crc32Signer{}, cache),
+		Cached(Salted(Crc32Signer{}, "1"), cache),
+		Cached(Salted(Crc32Signer{}, "2"), cache),
+		Cached(Md5Signer{}, cache),
+		Cached(HmacSha256Signer{Key: []byte("a")}, cache),
+		Cached(HmacSha256Signer{Key: []byte("b")}, cache),
+	}
+
+	hashes := make(map[string]bool)
+	for _, signer := range signers {
+		hash, err := signer.Sign(context.Background(), "0")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		hashes[hash] = true
+	}
+
+	if len(hashes) != len(signers) || cache.Stats().Misses != uint64(len(signers)) {
+		t.Errorf("algorithms, salts or keys share cache entries: %v, %+v", hashes, cache.Stats())
+	}
+}
+
+func TestSignCacheErrorsNotCached(t *testing.T) {
+	cache := NewSignCache(10)
+	signer := &countingSigner{Signer: Crc32Signer{}, fails: 1}
+	cached := Cached(signer, cache)
+
+	if _, err := cached.Sign(context.Background(), "0"); !errors.Is(err, errFlaky) {
+		t.Errorf("expected flaky error, got %v", err)
+	}
+	if hash, err := cached.Sign(context.Background(), "0"); err != nil || hash != "4108050209" {
+		t.Errorf("unexpected result: %v, %v", hash, err)
+	}
+	if signer.calls != 2 {
+		t.Errorf("expected 2 calls, got %d", signer.calls)
+	}
+}
+
+func TestSignCachePanic(t *testing.T) {
+	cached := Cached(panickySigner{Signer: Crc32Signer{}, on: "0"}, NewSignCache(10))
+
+	// после паники ключ не должен остаться в обработке навсегда
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		_, err := cached.Sign(ctx, "0")
+		cancel()
+
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) || !errors.Is(err, errBoom) {
+			t.Errorf("expected panic error on call %d, got %v", i+1, err)
+		}
+	}
+
+	if hash, err := cached.Sign(context.Background(), "1"); err != nil || hash != "2212294583" {
+		t.Errorf("unexpected result: %v, %v", hash, err)
+	}
+}
+
+func TestSignerPipelineCache(t *testing.T) {
+	cache := NewSignCache(100)
+	checksum := &countingSigner{Signer: Crc32Signer{}}
+	cfg := SignerConfig{Digest: Md5Signer{}, Checksum: checksum, Cache: cache}
+
+	for run := 0; run < 2; run++ {
+		results, err := SignerPipeline(cfg).Collect(context.Background(), "0", "1", "1")
+		if err != nil || len(results) != 1 {
+			t.Fatalf("unexpected result: %v, %v", results, err)
+		}
+	}
+
+	// 8 вызовов crc32 на каждое из двух различных значений, повторы берутся из кеша
+	if checksum.calls != 2*8 {
+		t.Errorf("expected %d checksum calls, got %d", 2*8, checksum.calls)
+	}
+}
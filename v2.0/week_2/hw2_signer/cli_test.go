@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func xxhashOfSha256(data string) string {
+	digest, _ := Sha256Signer{}.Sign(context.Background(), data)
+	hash, _ := XXHashSigner{}.Sign(context.Background(), digest)
+	return hash
+}
+
+func TestSignLines(t *testing.T) {
+	cases := []struct {
+		args     []string
+		input    string
+		expected string
+	}{
+		{
+			args:     []string{},
+			input:    "0\n1\n1\n2\n3\n5\n8\n",
+			expected: "1173136728138862632818075107442090076184424490584241521304_1696913515191343735512658979631549563179965036907783101867_27225454331033649287118297354036464389062965355426795162684_29568666068035183841425683795340791879727309630931025356555_3994492081516972096677631278379039212655368881548151736_4958044192186797981418233587017209679042592862002427381542_4958044192186797981418233587017209679042592862002427381542\n",
+		},
+		{
+			// без CombineResults печатается хэш каждой строки в порядке ввода
+			args:     []string{"--stages=SingleHash,MultiHash", "--workers=1"},
+			input:    "0\n1\n",
+			expected: "29568666068035183841425683795340791879727309630931025356555\n4958044192186797981418233587017209679042592862002427381542\n",
+		},
+		{
+			args:     []string{"--stages=SingleHash", "--digest=sha256", "--checksum=xxhash"},
+			input:    "0",
+			expected: "7148434200721666028~" + xxhashOfSha256("0") + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		args, err := parseArgs(c.args)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %v", c.args, err)
+		}
+
+		out := &bytes.Buffer{}
+		err = signLines(context.Background(), strings.NewReader(c.input), out, args)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %v", c.args, err)
+		}
+
+		if out.String() != c.expected {
+			t.Errorf("results not match for %v\nGot:\n%s\nExpected:\n%s", c.args, out.String(), c.expected)
+		}
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	cases := [][]string{
+		{"--stages=SingleHash,Foo"},
+		{"--digest=md4"},
+		{"--workers=0"},
+		{"--verbose"},
+		{"a.txt", "b.txt"},
+	}
+
+	for _, args := range cases {
+		if _, err := parseArgs(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	metrics := NewPipelineMetrics(nil)
+	addr, err := serveMetrics("127.0.0.1:0", metrics)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for /metrics, got %d", resp.StatusCode)
+	}
+
+	// адрес уже занят, ошибка видна сразу, а не теряется в горутине
+	if _, err := serveMetrics(addr.String(), metrics); err == nil {
+		t.Errorf("expected error for a busy address")
+	}
+	if _, err := serveMetrics("bad address", metrics); err == nil {
+		t.Errorf("expected error for a bad address")
+	}
+}
+
+func TestSignLinesCancelBlockedRead(t *testing.T) {
+	args, err := parseArgs([]string{"--digest=md5", "--checksum=crc32"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// как stdin, который никто не закрывает
+	r, w := io.Pipe()
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = signLines(ctx, r, &bytes.Buffer{}, args)
+	end := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if end > time.Second {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, time.Second)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var fakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// clockStep - сдвиг часов на d, как только на них спят sleepers горутин
+type clockStep struct {
+	sleepers int
+	d        time.Duration
+}
+
+// driveClock проходит шаги по порядку. Таймаут только страхует от зависания
+// при неверном числе спящих, на время в тесте он не влияет.
+func driveClock(t *testing.T, clock *FakeClock, steps ...clockStep) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i, step := range steps {
+		if err := clock.BlockUntil(ctx, step.sleepers); err != nil {
+			t.Fatalf("step %d: expected %d sleepers, got %d", i, step.sleepers, clock.Sleepers())
+		}
+		clock.Advance(step.d)
+	}
+}
+
+// signerSteps - расписание конвейера на n значений, когда все они идут
+// параллельно: md5 по 10мс по очереди, каждый crc32 по секунде. Последний шаг
+// будит все MultiHash сразу, так что точным выходит только общее время.
+func signerSteps(n int) []clockStep {
+	steps := make([]clockStep, 0, 2*n+2)
+	// md5 по очереди, у каждого значения уже спит crc32(data), у готовых ещё и crc32(md5)
+	for k := 1; k <= n; k++ {
+		steps = append(steps, clockStep{n + k, 10 * time.Millisecond})
+	}
+	// все crc32(data) заканчиваются на первой секунде
+	steps = append(steps, clockStep{2 * n, time.Second - time.Duration(n)*10*time.Millisecond})
+	// crc32(md5) заканчиваются по одному, значение уходит в MultiHash на 6 crc32
+	for j := 1; j <= n; j++ {
+		steps = append(steps, clockStep{n - j + 1 + 6*(j-1), 10 * time.Millisecond})
+	}
+	return append(steps, clockStep{6 * n, time.Second})
+}
+
+func TestFakeClockAdvance(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+
+	late := clock.NewTimer(2 * time.Second)
+	early := clock.NewTimer(time.Second)
+	stopped := clock.NewTimer(time.Second)
+	if !stopped.Stop() || clock.Sleepers() != 2 {
+		t.Fatalf("expected 2 sleepers after Stop, got %d", clock.Sleepers())
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	select {
+	case now := <-early.C():
+		if !now.Equal(fakeEpoch.Add(1500 * time.Millisecond)) {
+			t.Errorf("unexpected fire time %s", now)
+		}
+	default:
+		t.Errorf("early timer not fired")
+	}
+	select {
+	case <-late.C():
+		t.Errorf("late timer fired too early")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if _, ok := <-late.C(); !ok || clock.Sleepers() != 0 {
+		t.Errorf("late timer not fired")
+	}
+}
+
+func TestSignerPipelineVirtualTime(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+
+	// те же задержки, что у DataSignerMd5 и DataSignerCrc32, но в виртуальном времени
+	cfg := SignerConfig{
+		Digest:        Delayed(Md5Signer{}, 10*time.Millisecond, clock),
+		Checksum:      Delayed(Crc32Signer{}, time.Second, clock),
+		DigestLimiter: NewSemaphoreWithClock(1, clock),
+	}
+
+	var (
+		results []string
+		err     error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, err = SignerPipeline(cfg).Collect(context.Background(), "0", "1", "1", "2", "3", "5", "8")
+	}()
+	driveClock(t, clock, signerSteps(7)...)
+	<-done
+
+	if err != nil || len(results) != 1 || results[0] != fibCombined {
+		t.Errorf("results not match\nGot: %v, %v\nExpected: %v", results, err, fibCombined)
+	}
+	// md5 по очереди 7*10мс, crc32 параллельно: секунда на SingleHash и секунда на MultiHash
+	if virtual := clock.Now().Sub(fakeEpoch); virtual != 2070*time.Millisecond {
+		t.Errorf("unexpected virtual time\nGot: %s\nExpected: 2.07s", virtual)
+	}
+}
+
+func TestTokenBucketVirtualTime(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	bucket := NewTokenBucketWithClock(10, 1, clock)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			release, err := bucket.Acquire(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			release()
+		}
+	}()
+	// первый из запаса, каждый следующий ждёт 100мс
+	for i := 0; i < 4; i++ {
+		driveClock(t, clock, clockStep{1, 100 * time.Millisecond})
+	}
+	<-done
+
+	if virtual := clock.Now().Sub(fakeEpoch); virtual != 400*time.Millisecond {
+		t.Errorf("unexpected virtual time\nGot: %s\nExpected: 400ms", virtual)
+	}
+	if stats := bucket.Stats(); stats.Acquired != 5 || stats.MaxWait != 100*time.Millisecond {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRetryPolicyVirtualTime(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	flakyCrc32 := &countingSigner{Signer: Crc32Signer{}, fails: 2}
+
+	var (
+		hash string
+		err  error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hash, err = Retried(flakyCrc32, RetryPolicy{BaseDelay: time.Second, Clock: clock}).Sign(context.Background(), "0")
+	}()
+	// паузы 1с и 2с
+	driveClock(t, clock, clockStep{1, time.Second}, clockStep{1, 2 * time.Second})
+	<-done
+
+	if err != nil || hash != "4108050209" {
+		t.Errorf("unexpected result: %v, %v", hash, err)
+	}
+	if virtual := clock.Now().Sub(fakeEpoch); virtual != 3*time.Second {
+		t.Errorf("unexpected virtual time\nGot: %s\nExpected: 3s", virtual)
+	}
+}
+
+func TestFakeClockBlockUntil(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := clock.BlockUntil(ctx, 1); err == nil {
+		t.Errorf("expected error without sleepers")
+	}
+
+	go clock.NewTimer(time.Second)
+	if err := clock.BlockUntil(context.Background(), 1); err != nil || clock.Sleepers() != 1 {
+		t.Errorf("unexpected result: %v, %d sleepers", err, clock.Sleepers())
+	}
+}
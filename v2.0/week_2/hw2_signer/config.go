@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	stageSingleHash     = "SingleHash"
+	stageMultiHash      = "MultiHash"
+	stageCombineResults = "CombineResults"
+)
+
+// StageFactory builds a stage with the signers of cfg and workers workers, 0
+// means the default of the stage.
+type StageFactory func(cfg SignerConfig, workers int) Stage[string, string]
+
+var (
+	stagesMu sync.RWMutex
+	stages   = map[string]StageFactory{
+		stageSingleHash: func(cfg SignerConfig, workers int) Stage[string, string] {
+			if workers > 0 {
+				cfg.SingleHashWorkers = workers
+			}
+			return NewSingleHashStage(cfg)
+		},
+		stageMultiHash: func(cfg SignerConfig, workers int) Stage[string, string] {
+			if workers > 0 {
+				cfg.MultiHashWorkers = workers
+			}
+			return NewMultiHashStage(cfg)
+		},
+		stageCombineResults: func(cfg SignerConfig, _ int) Stage[string, string] {
+			return cfg.combineResultsStage()
+		},
+	}
+)
+
+// RegisterStage makes a stage available to pipeline configs under name. It
+// panics if name is already taken, like the registries of the standard library.
+func RegisterStage(name string, factory StageFactory) {
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+
+	if _, ok := stages[name]; ok {
+		panic("stage " + name + " is already registered")
+	}
+	stages[name] = factory
+}
+
+func stageByName(name string) (StageFactory, error) {
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+
+	factory, ok := stages[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown stage %q", name)
+	}
+	return factory, nil
+}
+
+// StageConfig is a stage of PipelineConfig.
+type StageConfig struct {
+	// Name is a registered stage: SingleHash, MultiHash, CombineResults or one of RegisterStage.
+	Name    string `json:"name" yaml:"name"`
+	Workers int    `json:"workers,omitempty" yaml:"workers,omitempty"`
+	// Buffer is the room of the output channel, MaxInputDataLen by default.
+	Buffer int `json:"buffer,omitempty" yaml:"buffer,omitempty"`
+}
+
+// PipelineConfig is the declarative form of a signer pipeline, see LoadPipeline.
+type PipelineConfig struct {
+	// Digest and Checksum are algorithms of SignerByName, md5 and crc32 by default.
+	Digest   string        `json:"digest,omitempty" yaml:"digest,omitempty"`
+	Checksum string        `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	Key      string        `json:"key,omitempty" yaml:"key,omitempty"`
+	Salt     string        `json:"salt,omitempty" yaml:"salt,omitempty"`
+	Ordered  bool          `json:"ordered,omitempty" yaml:"ordered,omitempty"`
+	Stages   []StageConfig `json:"stages" yaml:"stages"`
+}
+
+// DefaultStages is the SingleHash -> MultiHash -> CombineResults chain.
+func DefaultStages() []StageConfig {
+	return []StageConfig{{Name: stageSingleHash}, {Name: stageMultiHash}, {Name: stageCombineResults}}
+}
+
+func signerOrDefault(name, fallback string, key []byte) (Signer, error) {
+	if name == "" {
+		name = fallback
+	}
+	signer, ok := SignerByName(name, key)
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm %q", name)
+	}
+	return signer, nil
+}
+
+// SignerConfig applies the signers, salt, order and worker counts of c to base,
+// which keeps the settings a file can't describe, like Metrics or Cache.
+func (c PipelineConfig) SignerConfig(base SignerConfig) (SignerConfig, error) {
+	var err error
+	base.Digest, err = signerOrDefault(c.Digest, "md5", []byte(c.Key))
+	if err != nil {
+		return base, err
+	}
+	base.Checksum, err = signerOrDefault(c.Checksum, "crc32", []byte(c.Key))
+	if err != nil {
+		return base, err
+	}
+
+	base.Salt = c.Salt
+	base.Ordered = c.Ordered
+	for _, stage := range c.Stages {
+		switch {
+		case stage.Workers <= 0:
+		case stage.Name == stageSingleHash:
+			base.SingleHashWorkers = stage.Workers
+		case stage.Name == stageMultiHash:
+			base.MultiHashWorkers = stage.Workers
+		}
+	}
+	return base, nil
+}
+
+// Build chains the stages of c with the settings of base, see SignerConfig.
+// With a Tracer the built-in stages pass traced items to each other.
+func (c PipelineConfig) Build(base SignerConfig) (Pipeline[string, string], error) {
+	var p Pipeline[string, string]
+	if len(c.Stages) == 0 {
+		return p, fmt.Errorf("expected at least one stage")
+	}
+
+	cfg, err := c.SignerConfig(base)
+	if err != nil {
+		return p, err
+	}
+
+	for _, stageCfg := range c.Stages {
+		if _, err := stageByName(stageCfg.Name); err != nil {
+			return p, err
+		}
+		if stageCfg.Workers < 0 || stageCfg.Buffer < 0 {
+			return p, fmt.Errorf("stage %s: workers and buffer can't be negative", stageCfg.Name)
+		}
+	}
+
+	if cfg.Tracer != nil {
+		return c.buildTraced(cfg), nil
+	}
+
+	for i, stageCfg := range c.Stages {
+		factory, _ := stageByName(stageCfg.Name)
+		stage := factory(cfg, stageCfg.Workers)
+		if i == 0 {
+			p = NewBufferedPipeline(stageCfg.Name, stageCfg.buffer(), stage)
+		} else {
+			p = ThenBuffered(p, stageCfg.Name, stageCfg.buffer(), stage)
+		}
+	}
+
+	return p, nil
+}
+
+func (c PipelineConfig) buildTraced(cfg SignerConfig) Pipeline[string, string] {
+	last := len(c.Stages) - 1
+	first := c.Stages[0]
+	if last == 0 {
+		return NewBufferedPipeline(first.Name, first.buffer(), untraced(tracedStage(cfg, first)))
+	}
+
+	p := NewBufferedPipeline(first.Name, first.buffer(), startTraces(tracedStage(cfg, first)))
+	for _, stageCfg := range c.Stages[1:last] {
+		p = ThenBuffered(p, stageCfg.Name, stageCfg.buffer(), tracedStage(cfg, stageCfg))
+	}
+	return ThenBuffered(p, c.Stages[last].Name, c.Stages[last].buffer(), dropTraces(tracedStage(cfg, c.Stages[last])))
+}
+
+// tracedStage builds a stage on traced items. Registered stages don't know
+// about traces, their results start new ones.
+func tracedStage(cfg SignerConfig, stageCfg StageConfig) Stage[tracedItem, tracedItem] {
+	switch stageCfg.Name {
+	case stageSingleHash:
+		if stageCfg.Workers > 0 {
+			cfg.SingleHashWorkers = stageCfg.Workers
+		}
+		return cfg.tracedSingleHashStage()
+	case stageMultiHash:
+		if stageCfg.Workers > 0 {
+			cfg.MultiHashWorkers = stageCfg.Workers
+		}
+		return cfg.tracedMultiHashStage()
+	case stageCombineResults:
+		return cfg.tracedCombineResultsStage()
+	}
+
+	factory, _ := stageByName(stageCfg.Name)
+	return opaqueTraces(factory(cfg, stageCfg.Workers))
+}
+
+func (s StageConfig) buffer() int {
+	if s.Buffer == 0 {
+		return MaxInputDataLen
+	}
+	return s.Buffer
+}
+
+// ReadPipelineConfig reads a JSON file, or a YAML one if its extension is .yaml or .yml.
+// Unknown fields are errors, so typos don't go unnoticed.
+func ReadPipelineConfig(path string) (PipelineConfig, error) {
+	var c PipelineConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&c)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// LoadPipeline builds the pipeline described by the config file at path.
+func LoadPipeline(path string) (Pipeline[string, string], error) {
+	c, err := ReadPipelineConfig(path)
+	if err != nil {
+		return Pipeline[string, string]{}, err
+	}
+
+	p, err := c.Build(SignerConfig{})
+	if err != nil {
+		return p, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
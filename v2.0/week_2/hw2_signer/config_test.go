@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestLoadPipeline(t *testing.T) {
+	configs := map[string]string{
+		"pipeline.json": `{
+			"digest": "md5",
+			"checksum": "crc32",
+			"stages": [
+				{"name": "SingleHash", "workers": 2, "buffer": 1},
+				{"name": "MultiHash", "workers": 3},
+				{"name": "CombineResults"}
+			]
+		}`,
+		"pipeline.yaml": `
+stages:
+  - name: SingleHash
+    workers: 2
+    buffer: 1
+  - name: MultiHash
+    workers: 3
+  - name: CombineResults
+`,
+	}
+
+	for name, content := range configs {
+		p, err := LoadPipeline(writeConfig(t, name, content))
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", name, err)
+		}
+
+		results, err := p.Collect(context.Background(), "0", "1", "1", "2", "3", "5", "8")
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", name, err)
+		}
+		if len(results) != 1 || results[0] != fibCombined {
+			t.Errorf("results not match for %s\nGot: %v\nExpected: %v", name, results, fibCombined)
+		}
+	}
+}
+
+func TestReadPipelineConfig(t *testing.T) {
+	path := writeConfig(t, "pipeline.yml", `
+digest: sha256
+checksum: xxhash
+salt: pepper
+ordered: true
+stages:
+  - name: SingleHash
+    workers: 4
+`)
+
+	c, err := ReadPipelineConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := c.SignerConfig(SignerConfig{MultiHashWorkers: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Digest.Name() != "sha256" || cfg.Checksum.Name() != "xxhash" || cfg.Salt != "pepper" || !cfg.Ordered {
+		t.Errorf("config not match\nGot: %+v", cfg)
+	}
+	// воркеры MultiHash не указаны в файле и остаются от базового конфига
+	if cfg.SingleHashWorkers != 4 || cfg.MultiHashWorkers != 5 {
+		t.Errorf("workers not match\nGot: %d, %d\nExpected: 4, 5", cfg.SingleHashWorkers, cfg.MultiHashWorkers)
+	}
+}
+
+func TestLoadPipelineErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown-stage.json":     `{"stages": [{"name": "SingleHash"}, {"name": "Foo"}]}`,
+		"unknown-algorithm.json": `{"digest": "md4", "stages": [{"name": "SingleHash"}]}`,
+		"unknown-field.json":     `{"stages": [{"name": "SingleHash", "worker": 2}]}`,
+		"unknown-field.yaml":     "stages:\n  - name: SingleHash\n    worker: 2\n",
+		"negative-workers.yaml":  "stages:\n  - name: SingleHash\n    workers: -1\n",
+		"no-stages.json":         `{"digest": "md5"}`,
+		"broken.json":            `{"stages": [`,
+	}
+
+	for name, content := range cases {
+		if _, err := LoadPipeline(writeConfig(t, name, content)); err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+
+	if _, err := LoadPipeline(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func TestRegisterStage(t *testing.T) {
+	RegisterStage("Upper", func(_ SignerConfig, workers int) Stage[string, string] {
+		return ParallelStage(workers, func(_ context.Context, item string) (string, error) {
+			return strings.ToUpper(item), nil
+		})
+	})
+	t.Cleanup(func() {
+		stagesMu.Lock()
+		delete(stages, "Upper")
+		stagesMu.Unlock()
+	})
+
+	c := PipelineConfig{Stages: []StageConfig{{Name: stageSingleHash}, {Name: "Upper", Workers: 2}}}
+	p, err := c.Build(SignerConfig{Ordered: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results, err := p.Collect(context.Background(), "0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "4108050209~502633748"
+	if len(results) != 1 || results[0] != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, expected)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic on duplicate stage")
+		}
+	}()
+	RegisterStage(stageSingleHash, nil)
+}
+
+func TestParseArgsConfig(t *testing.T) {
+	path := writeConfig(t, "pipeline.json", `{"digest": "sha256", "checksum": "xxhash", "stages": [{"name": "SingleHash"}]}`)
+
+	// флаги переопределяют файл независимо от порядка
+	args, err := parseArgs([]string{"--digest=md5", "--config=" + path, "--checksum=crc32"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	err = signLines(context.Background(), strings.NewReader("0\n"), out, args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "4108050209~502633748\n"
+	if out.String() != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", out.String(), expected)
+	}
+}
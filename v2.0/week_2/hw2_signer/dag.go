@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrInvalidGraph is wrapped by every error Graph.Validate reports.
+	ErrInvalidGraph = errors.New("invalid graph")
+	// ErrZipUneven is returned by a Zip node whose inputs end after a different number of items.
+	ErrZipUneven = errors.New("zip inputs ended unevenly")
+)
+
+type nodeKind int
+
+const (
+	stageNode nodeKind = iota
+	mergeNode
+	zipNode
+)
+
+type graphNode struct {
+	name   string
+	kind   nodeKind
+	stage  Stage[string, string]
+	join   func(items []string) string
+	inputs []string
+}
+
+// Graph is a pipeline of named nodes that may fan a stream out to several
+// branches and merge or zip them back. The output of a node is sent to every
+// node reading it.
+type Graph struct {
+	source  string
+	nodes   []*graphNode
+	outputs []string
+}
+
+// NewGraph starts a graph whose input stream is the node source.
+func NewGraph(source string) *Graph {
+	return &Graph{source: source}
+}
+
+func (g *Graph) add(node *graphNode) *Graph {
+	g.nodes = append(g.nodes, node)
+	return g
+}
+
+// Stage adds a node running stage over the output of input.
+func (g *Graph) Stage(name string, stage Stage[string, string], input string) *Graph {
+	return g.add(&graphNode{name: name, kind: stageNode, stage: stage, inputs: []string{input}})
+}
+
+// Merge adds a node emitting the items of all inputs as they arrive.
+func (g *Graph) Merge(name string, inputs ...string) *Graph {
+	return g.add(&graphNode{name: name, kind: mergeNode, inputs: inputs})
+}
+
+// Zip adds a node joining the n-th items of all inputs into one item. The
+// inputs have to keep the order of the items, like OrderedParallelStage does.
+func (g *Graph) Zip(name string, join func(items []string) string, inputs ...string) *Graph {
+	return g.add(&graphNode{name: name, kind: zipNode, join: join, inputs: inputs})
+}
+
+// Output makes the results of the nodes the results of the graph.
+func (g *Graph) Output(names ...string) *Graph {
+	g.outputs = append(g.outputs, names...)
+	return g
+}
+
+func invalidGraph(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidGraph, fmt.Sprintf(format, args...))
+}
+
+// sorted validates the graph and returns its nodes in an order where every
+// node comes after its inputs.
+func (g *Graph) sorted() ([]*graphNode, error) {
+	nodes := map[string]*graphNode{}
+	for _, node := range g.nodes {
+		if node.name == g.source || nodes[node.name] != nil {
+			return nil, invalidGraph("duplicate node %q", node.name)
+		}
+		nodes[node.name] = node
+	}
+
+	used := map[string]bool{}
+	for _, node := range g.nodes {
+		if node.kind != stageNode && len(node.inputs) < 2 {
+			return nil, invalidGraph("node %q needs at least two inputs", node.name)
+		}
+		if node.kind == stageNode && node.stage == nil || node.kind == zipNode && node.join == nil {
+			return nil, invalidGraph("node %q has nothing to run", node.name)
+		}
+		for _, input := range node.inputs {
+			if input != g.source && nodes[input] == nil {
+				return nil, invalidGraph("node %q reads unknown node %q", node.name, input)
+			}
+			used[input] = true
+		}
+	}
+
+	if len(g.outputs) == 0 {
+		return nil, invalidGraph("no outputs")
+	}
+	for _, output := range g.outputs {
+		if output != g.source && nodes[output] == nil {
+			return nil, invalidGraph("unknown output %q", output)
+		}
+		used[output] = true
+	}
+	for _, node := range g.nodes {
+		if !used[node.name] {
+			return nil, invalidGraph("output of node %q is not used", node.name)
+		}
+	}
+
+	// Kahn's algorithm, the nodes left unsorted are on a cycle
+	done := map[string]bool{g.source: true}
+	order := make([]*graphNode, 0, len(g.nodes))
+	for len(order) < len(g.nodes) {
+		progress := false
+		for _, node := range g.nodes {
+			if done[node.name] {
+				continue
+			}
+
+			ready := true
+			for _, input := range node.inputs {
+				ready = ready && done[input]
+			}
+			if ready {
+				done[node.name] = true
+				order = append(order, node)
+				progress = true
+			}
+		}
+
+		if !progress {
+			for _, node := range g.nodes {
+				if !done[node.name] {
+					return nil, invalidGraph("node %q is on a cycle", node.name)
+				}
+			}
+		}
+	}
+
+	return order, nil
+}
+
+// Validate checks that every input exists, every output is used and there are no cycles.
+func (g *Graph) Validate() error {
+	_, err := g.sorted()
+	return err
+}
+
+// broadcastItems copies every item of in to n channels.
+func broadcastItems[T any](ctx context.Context, sg *stageGroup, in <-chan T, n int) []<-chan T {
+	outs := make([]chan T, n)
+	result := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T, MaxInputDataLen)
+		result[i] = outs[i]
+	}
+
+	sg.wg.Add(1)
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+			drainItems(ctx, in)
+			sg.wg.Done()
+		}()
+
+		for {
+			item, ok := receiveItem(ctx, in)
+			if !ok {
+				return
+			}
+			for _, out := range outs {
+				if !sendItem(ctx, out, item) {
+					return
+				}
+			}
+		}
+	}()
+
+	return result
+}
+
+func mergeItems(ctx context.Context, ins []<-chan string, out chan<- string) error {
+	wg := &sync.WaitGroup{}
+	for _, in := range ins {
+		wg.Add(1)
+		go func(in <-chan string) {
+			defer wg.Done()
+			for {
+				item, ok := receiveItem(ctx, in)
+				if !ok || !sendItem(ctx, out, item) {
+					return
+				}
+			}
+		}(in)
+	}
+	wg.Wait()
+	return nil
+}
+
+func zipItems(ctx context.Context, ins []<-chan string, out chan<- string, join func(items []string) string) error {
+	for {
+		items := make([]string, 0, len(ins))
+		for _, in := range ins {
+			item, ok := receiveItem(ctx, in)
+			if !ok {
+				break
+			}
+			items = append(items, item)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if len(items) < len(ins) {
+			// the inputs have to end together
+			uneven := len(items) > 0
+			for _, in := range ins {
+				if _, ok := receiveItem(ctx, in); ok {
+					uneven = true
+				}
+				drainItems(ctx, in)
+			}
+			if uneven {
+				return ErrZipUneven
+			}
+			return nil
+		}
+
+		if !sendItem(ctx, out, join(items)) {
+			return nil
+		}
+	}
+}
+
+// startJoin runs a merge or zip node like startStage runs a stage.
+func startJoin(ctx context.Context, sg *stageGroup, node *graphNode, ins []<-chan string) <-chan string {
+	out := make(chan string, MaxInputDataLen)
+
+	sg.wg.Add(1)
+	go func() {
+		defer func() {
+			close(out)
+			for _, in := range ins {
+				drainItems(ctx, in)
+			}
+			sg.wg.Done()
+		}()
+
+		var err error
+		if node.kind == zipNode {
+			err = zipItems(ctx, ins, out, node.join)
+		} else {
+			err = mergeItems(ctx, ins, out)
+		}
+		if err != nil {
+			sg.fail(fmt.Errorf("%s: %w", node.name, err))
+		}
+	}()
+
+	return out
+}
+
+// Run validates the graph, feeds in through it and hands every result to sink
+// with the name of the output node. Errors are handled like in Pipeline.Run.
+func (g *Graph) Run(ctx context.Context, in <-chan string, sink func(output, item string) error) error {
+	order, err := g.sorted()
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sg := &stageGroup{cancel: cancel}
+
+	// readers of every node in the order they take their channels, "" is the sink
+	readers := map[string][]string{}
+	for _, node := range order {
+		for _, input := range node.inputs {
+			readers[input] = append(readers[input], node.name)
+		}
+	}
+	for _, output := range g.outputs {
+		readers[output] = append(readers[output], "")
+	}
+
+	channels := map[string][]<-chan string{}
+	connect := func(name string, out <-chan string) {
+		if len(readers[name]) == 1 {
+			channels[name] = []<-chan string{out}
+		} else {
+			channels[name] = broadcastItems(runCtx, sg, out, len(readers[name]))
+		}
+	}
+	take := func(name string) <-chan string {
+		ch := channels[name][0]
+		channels[name] = channels[name][1:]
+		return ch
+	}
+
+	connect(g.source, in)
+	for _, node := range order {
+		ins := make([]<-chan string, 0, len(node.inputs))
+		for _, input := range node.inputs {
+			ins = append(ins, take(input))
+		}
+
+		if node.kind == stageNode {
+			connect(node.name, startStage(runCtx, sg, node.name, node.stage, ins[0], MaxInputDataLen))
+		} else {
+			connect(node.name, startJoin(runCtx, sg, node, ins))
+		}
+	}
+
+	type result struct {
+		output string
+		item   string
+	}
+	results := make(chan result)
+	outputsWg := &sync.WaitGroup{}
+	for _, output := range g.outputs {
+		outputsWg.Add(1)
+		go func(output string, ch <-chan string) {
+			defer outputsWg.Done()
+			for item := range ch {
+				results <- result{output, item}
+			}
+		}(output, take(output))
+	}
+	go func() {
+		outputsWg.Wait()
+		close(results)
+	}()
+
+	failed := false
+	for r := range results {
+		if failed {
+			continue
+		}
+		if err := sink(r.output, r.item); err != nil {
+			failed = true
+			sg.fail(err)
+		}
+	}
+
+	sg.wg.Wait()
+
+	if sg.err != nil {
+		return sg.err
+	}
+
+	return ctx.Err()
+}
+
+// Collect runs the graph over inputs and returns the results of every output node.
+func (g *Graph) Collect(ctx context.Context, inputs ...string) (map[string][]string, error) {
+	in := make(chan string, len(inputs))
+	for _, input := range inputs {
+		in <- input
+	}
+	close(in)
+
+	results := map[string][]string{}
+	err := g.Run(ctx, in, func(output, item string) error {
+		results[output] = append(results[output], item)
+		return nil
+	})
+
+	return results, err
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sha256Stage() Stage[string, string] {
+	return OrderedParallelStage(0, Sha256Signer{}.Sign)
+}
+
+// echoStage передаёт значения дальше как есть, один воркер сохраняет порядок.
+func echoStage() Stage[string, string] {
+	return ParallelStage(1, func(_ context.Context, data string) (string, error) {
+		return data, nil
+	})
+}
+
+func TestGraphZip(t *testing.T) {
+	cfg := SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}, Ordered: true}
+
+	g := NewGraph("in").
+		Stage("single", NewSingleHashStage(cfg), "in").
+		Stage("sha", sha256Stage(), "in").
+		Zip("pair", func(items []string) string { return strings.Join(items, "|") }, "single", "sha").
+		Output("pair")
+
+	results, err := g.Collect(context.Background(), "0", "1", "2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	expected := []string{}
+	for _, data := range []string{"0", "1", "2"} {
+		single, _ := cfg.signers().singleHash(ctx, data)
+		sha, _ := Sha256Signer{}.Sign(ctx, data)
+		expected = append(expected, single+"|"+sha)
+	}
+	if !reflect.DeepEqual(results["pair"], expected) {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results["pair"], expected)
+	}
+}
+
+func TestGraphMergeAndOutputs(t *testing.T) {
+	cfg := SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}}
+
+	// сырые значения тоже выходят наружу, поток in читают три узла
+	g := NewGraph("in").
+		Stage("single", NewSingleHashStage(cfg), "in").
+		Stage("sha", sha256Stage(), "in").
+		Merge("all", "single", "sha").
+		Stage("combined", cfg.combineResultsStage(), "all").
+		Output("combined", "in")
+
+	results, err := g.Collect(context.Background(), "0", "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	parts := []string{}
+	for _, data := range []string{"0", "1"} {
+		single, _ := cfg.signers().singleHash(ctx, data)
+		sha, _ := Sha256Signer{}.Sign(ctx, data)
+		parts = append(parts, single, sha)
+	}
+	sort.Strings(parts)
+
+	if len(results["combined"]) != 1 || results["combined"][0] != strings.Join(parts, "_") {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results["combined"], strings.Join(parts, "_"))
+	}
+	if !reflect.DeepEqual(results["in"], []string{"0", "1"}) {
+		t.Errorf("inputs not passed through: %v", results["in"])
+	}
+}
+
+func TestGraphZipUneven(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	g := NewGraph("in").
+		Stage("all", echoStage(), "in").
+		Stage("odd", ParallelStage(1, func(_ context.Context, data string) (string, error) {
+			if data == "1" {
+				return "", ErrSkipItem
+			}
+			return data, nil
+		}), "in").
+		Zip("pair", func(items []string) string { return strings.Join(items, "|") }, "all", "odd").
+		Output("pair")
+
+	_, err := g.Collect(context.Background(), "0", "1", "2")
+	if !errors.Is(err, ErrZipUneven) {
+		t.Errorf("expected ErrZipUneven, got %v", err)
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestGraphValidate(t *testing.T) {
+	join := func(items []string) string { return strings.Join(items, "") }
+	cases := []struct {
+		graph    *Graph
+		expected string
+	}{
+		{
+			NewGraph("in").Stage("a", echoStage(), "b").Stage("b", echoStage(), "a").Stage("c", echoStage(), "in").Merge("d", "c", "b").Output("d"),
+			"invalid graph: node \"a\" is on a cycle",
+		},
+		{
+			NewGraph("in").Stage("a", echoStage(), "in").Stage("b", echoStage(), "in").Output("a"),
+			"invalid graph: output of node \"b\" is not used",
+		},
+		{
+			NewGraph("in").Stage("a", echoStage(), "nowhere").Output("a"),
+			"invalid graph: node \"a\" reads unknown node \"nowhere\"",
+		},
+		{
+			NewGraph("in").Stage("a", echoStage(), "in").Stage("a", echoStage(), "in").Output("a"),
+			"invalid graph: duplicate node \"a\"",
+		},
+		{
+			NewGraph("in").Zip("z", join, "in").Output("z"),
+			"invalid graph: node \"z\" needs at least two inputs",
+		},
+		{
+			NewGraph("in").Stage("a", echoStage(), "in"),
+			"invalid graph: no outputs",
+		},
+	}
+
+	for _, c := range cases {
+		err := c.graph.Validate()
+		if !errors.Is(err, ErrInvalidGraph) || err.Error() != c.expected {
+			t.Errorf("validation not match\nGot: %v\nExpected: %s", err, c.expected)
+		}
+
+		if _, err := c.graph.Collect(context.Background(), "0"); !errors.Is(err, ErrInvalidGraph) {
+			t.Errorf("invalid graph run: %v", err)
+		}
+	}
+}
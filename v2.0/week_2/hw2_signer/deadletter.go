@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DeadLetter is an item a stage failed to process in skip mode.
+type DeadLetter struct {
+	Stage string
+	Item  interface{}
+	Err   error
+}
+
+func (l DeadLetter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Stage string      `json:"stage"`
+		Item  interface{} `json:"item"`
+		Error string      `json:"error"`
+	}{l.Stage, l.Item, l.Err.Error()})
+}
+
+// DeadLetterSink receives the failed items. Put is called concurrently by the
+// workers of a stage, an error of Put fails the stage.
+type DeadLetterSink interface {
+	Put(ctx context.Context, letter DeadLetter) error
+}
+
+type chanSink chan<- DeadLetter
+
+func (ch chanSink) Put(ctx context.Context, letter DeadLetter) error {
+	select {
+	case ch <- letter:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DeadLetterChan sends the failed items to ch, the stage waits for ch to accept them.
+func DeadLetterChan(ch chan<- DeadLetter) DeadLetterSink {
+	return chanSink(ch)
+}
+
+// JSONLSink writes every failed item as a line of JSON.
+type JSONLSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{enc: json.NewEncoder(w)}
+}
+
+func (s *JSONLSink) Put(_ context.Context, letter DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(letter)
+}
+
+// StageReport counts the items a stage processed in skip mode.
+type StageReport struct {
+	Stage     string
+	Succeeded int64
+	Failed    int64
+}
+
+// DeadLetters switches stages to skip mode: failed items go to the sink and the
+// stage goes on with the next one. It can be shared by all stages of a run.
+type DeadLetters struct {
+	sink DeadLetterSink
+
+	mu     sync.Mutex
+	stages []StageReport
+}
+
+// NewDeadLetters records failed items in sink, nil sink only counts them.
+func NewDeadLetters(sink DeadLetterSink) *DeadLetters {
+	return &DeadLetters{sink: sink}
+}
+
+func (d *DeadLetters) count(stage string, failed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	i := 0
+	for i < len(d.stages) && d.stages[i].Stage != stage {
+		i++
+	}
+	if i == len(d.stages) {
+		d.stages = append(d.stages, StageReport{Stage: stage})
+	}
+
+	if failed {
+		d.stages[i].Failed++
+	} else {
+		d.stages[i].Succeeded++
+	}
+}
+
+// put counts a failed item of stage and hands it to the sink.
+func (d *DeadLetters) put(ctx context.Context, stage string, item interface{}, err error) error {
+	d.count(stage, true)
+	if d.sink == nil {
+		return nil
+	}
+
+	if putErr := d.sink.Put(ctx, DeadLetter{Stage: stage, Item: item, Err: err}); putErr != nil {
+		return fmt.Errorf("dead letter of item %v: %w", item, putErr)
+	}
+	return nil
+}
+
+// Report returns the counts of every stage in the order the stages first reported.
+func (d *DeadLetters) Report() []StageReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]StageReport(nil), d.stages...)
+}
+
+// SkipFailed puts the items fn fails on, panics included, to the dead letters
+// of stage and drops them with ErrSkipItem. Cancellation of the run still fails fn.
+func SkipFailed[In, Out any](d *DeadLetters, stage string, fn func(ctx context.Context, item In) (Out, error)) func(ctx context.Context, item In) (Out, error) {
+	if d == nil {
+		return fn
+	}
+
+	return func(ctx context.Context, item In) (Out, error) {
+		result, err := safeCall(ctx, fn, item)
+		if err == nil {
+			d.count(stage, false)
+			return result, nil
+		}
+		if ctx.Err() != nil && isContextErr(err) {
+			return result, err
+		}
+
+		if putErr := d.put(ctx, stage, item, err); putErr != nil {
+			return result, putErr
+		}
+
+		if errors.Is(err, ErrSkipItem) {
+			return result, err
+		}
+		return result, fmt.Errorf("%w: %w", ErrSkipItem, err)
+	}
+}
+
+// ExecutePipelineReport runs jobs with ExecutePipelineErr and returns the counts
+// of the stages that record their failed items in d, like SignerJobs of a
+// config with DeadLetters d.
+func ExecutePipelineReport(ctx context.Context, d *DeadLetters, jobs ...jobErr) ([]StageReport, error) {
+	err := ExecutePipelineErr(ctx, jobs...)
+	return d.Report(), err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// failingSigner возвращает errBoom на значении on.
+type failingSigner struct {
+	Signer
+	on string
+}
+
+func (s failingSigner) Sign(ctx context.Context, data string) (string, error) {
+	if data == s.on {
+		return "", errBoom
+	}
+	return s.Signer.Sign(ctx, data)
+}
+
+func TestSignerPipelineDeadLetterChan(t *testing.T) {
+	letters := make(chan DeadLetter, 10)
+	cfg := SignerConfig{
+		Digest:      Md5Signer{},
+		Checksum:    failingSigner{Signer: Crc32Signer{}, on: "1"},
+		DeadLetters: NewDeadLetters(DeadLetterChan(letters)),
+	}
+
+	results, err := SignerPipeline(cfg).Collect(context.Background(), "0", "1", "2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(letters)
+
+	expected, _ := SignerPipeline(SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}}).Collect(context.Background(), "0", "2")
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, expected)
+	}
+
+	var got []DeadLetter
+	for letter := range letters {
+		got = append(got, letter)
+	}
+	if len(got) != 1 || got[0].Stage != "SingleHash" || got[0].Item != "1" || !errors.Is(got[0].Err, errBoom) {
+		t.Errorf("unexpected dead letters: %v", got)
+	}
+
+	expectedReport := []StageReport{
+		{Stage: "SingleHash", Succeeded: 2, Failed: 1},
+		{Stage: "MultiHash", Succeeded: 2, Failed: 0},
+	}
+	if report := cfg.DeadLetters.Report(); !reflect.DeepEqual(report, expectedReport) {
+		t.Errorf("report not match\nGot: %v\nExpected: %v", report, expectedReport)
+	}
+}
+
+func TestExecutePipelineErrDeadLetterJSONL(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cfg := SignerConfig{
+		Digest:      Md5Signer{},
+		Checksum:    panickySigner{Signer: Crc32Signer{}, on: "2"},
+		Ordered:     true,
+		DeadLetters: NewDeadLetters(NewJSONLSink(buf)),
+	}
+
+	var testResult interface{}
+	jobs := []jobErr{
+		func(ctx context.Context, in, out chan interface{}) error {
+			for i := 0; i < 4; i++ {
+				send(ctx, out, i)
+			}
+			return nil
+		},
+	}
+	jobs = append(jobs, SignerJobs(cfg)...)
+	jobs = append(jobs, func(ctx context.Context, in, out chan interface{}) error {
+		testResult, _ = receive(ctx, in)
+		return nil
+	})
+
+	if err := ExecutePipelineErr(context.Background(), jobs...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedLines := `{"stage":"SingleHash","item":"2","error":"panic on item 2: boom"}` + "\n"
+	if buf.String() != expectedLines {
+		t.Errorf("dead letters not match\nGot: %s\nExpected: %s", buf.String(), expectedLines)
+	}
+
+	cfg.DeadLetters = nil
+	cfg.Checksum = Crc32Signer{}
+	expected, _ := SignerPipeline(cfg).Collect(context.Background(), "0", "1", "3")
+	if testResult != expected[0] {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", testResult, expected[0])
+	}
+}
+
+func TestExecutePipelineReportUnexpectedItem(t *testing.T) {
+	letters := make(chan DeadLetter, 10)
+	cfg := SignerConfig{
+		Digest:      Md5Signer{},
+		Checksum:    Crc32Signer{},
+		Ordered:     true,
+		DeadLetters: NewDeadLetters(DeadLetterChan(letters)),
+	}
+
+	var testResult interface{}
+	jobs := []jobErr{
+		func(ctx context.Context, in, out chan interface{}) error {
+			for _, item := range []interface{}{0, 1.5, "1"} {
+				send(ctx, out, item)
+			}
+			return nil
+		},
+	}
+	jobs = append(jobs, SignerJobs(cfg)...)
+	jobs = append(jobs, func(ctx context.Context, in, out chan interface{}) error {
+		testResult, _ = receive(ctx, in)
+		return nil
+	})
+
+	report, err := ExecutePipelineReport(context.Background(), cfg.DeadLetters, jobs...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(letters)
+
+	letter := <-letters
+	if letter.Stage != "SingleHash" || letter.Item != 1.5 || !errors.Is(letter.Err, ErrUnexpectedItem) {
+		t.Errorf("unexpected dead letter: %v", letter)
+	}
+
+	expectedReport := []StageReport{
+		{Stage: "SingleHash", Succeeded: 2, Failed: 1},
+		{Stage: "MultiHash", Succeeded: 2, Failed: 0},
+	}
+	if !reflect.DeepEqual(report, expectedReport) {
+		t.Errorf("report not match\nGot: %v\nExpected: %v", report, expectedReport)
+	}
+
+	cfg.DeadLetters = nil
+	expected, _ := SignerPipeline(cfg).Collect(context.Background(), "0", "1")
+	if testResult != expected[0] {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", testResult, expected[0])
+	}
+}
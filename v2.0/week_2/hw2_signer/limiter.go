@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter guards a resource shared by concurrent callers.
+type Limiter interface {
+	// Acquire blocks until the caller may use the resource or ctx is done.
+	// release has to be called once the resource is not used anymore.
+	Acquire(ctx context.Context) (release func(), err error)
+	Stats() LimiterStats
+}
+
+// LimiterStats describe how long callers waited for a limiter.
+type LimiterStats struct {
+	Acquired  uint64
+	Cancelled uint64
+	Waiting   int64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+type limiterStats struct {
+	acquired  uint64
+	cancelled uint64
+	waiting   int64
+	totalWait int64
+	maxWait   int64
+}
+
+func (s *limiterStats) startWait(clock Clock) time.Time {
+	atomic.AddInt64(&s.waiting, 1)
+	return clock.Now()
+}
+
+func (s *limiterStats) endWait(clock Clock, start time.Time, err error) {
+	atomic.AddInt64(&s.waiting, -1)
+	if err != nil {
+		atomic.AddUint64(&s.cancelled, 1)
+		return
+	}
+
+	wait := int64(clock.Now().Sub(start))
+	atomic.AddUint64(&s.acquired, 1)
+	atomic.AddInt64(&s.totalWait, wait)
+	for {
+		prev := atomic.LoadInt64(&s.maxWait)
+		if wait <= prev || atomic.CompareAndSwapInt64(&s.maxWait, prev, wait) {
+			return
+		}
+	}
+}
+
+func (s *limiterStats) snapshot() LimiterStats {
+	return LimiterStats{
+		Acquired:  atomic.LoadUint64(&s.acquired),
+		Cancelled: atomic.LoadUint64(&s.cancelled),
+		Waiting:   atomic.LoadInt64(&s.waiting),
+		TotalWait: time.Duration(atomic.LoadInt64(&s.totalWait)),
+		MaxWait:   time.Duration(atomic.LoadInt64(&s.maxWait)),
+	}
+}
+
+// Semaphore lets at most n callers use the resource at the same time.
+type Semaphore struct {
+	slots chan struct{}
+	clock Clock
+	stats limiterStats
+}
+
+func NewSemaphore(n int) *Semaphore {
+	return NewSemaphoreWithClock(n, SystemClock)
+}
+
+// NewSemaphoreWithClock is NewSemaphore that times the waits with clock.
+func NewSemaphoreWithClock(n int, clock Clock) *Semaphore {
+	if n <= 0 {
+		n = 1
+	}
+	return &Semaphore{slots: make(chan struct{}, n), clock: clockOrSystem(clock)}
+}
+
+func (s *Semaphore) Acquire(ctx context.Context) (func(), error) {
+	start := s.stats.startWait(s.clock)
+
+	var err error
+	select {
+	case s.slots <- struct{}{}:
+		if err = ctx.Err(); err != nil {
+			<-s.slots
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.stats.endWait(s.clock, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { <-s.slots })
+	}, nil
+}
+
+func (s *Semaphore) Stats() LimiterStats {
+	return s.stats.snapshot()
+}
+
+// TokenBucket lets callers in at rate per second with bursts of up to burst calls.
+type TokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+	clock    Clock
+	stats    limiterStats
+}
+
+// NewTokenBucket refills the bucket on SystemClock. A bucket with rate <= 0
+// never refills: once the burst is spent, Acquire waits until ctx is done.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return NewTokenBucketWithClock(rate, burst, SystemClock)
+}
+
+// NewTokenBucketWithClock is NewTokenBucket that refills and waits on clock.
+func NewTokenBucketWithClock(rate float64, burst int, clock Clock) *TokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	clock = clockOrSystem(clock)
+	return &TokenBucket{
+		rate:     rate,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		lastFill: clock.Now(),
+		clock:    clock,
+	}
+}
+
+// take spends a token if there is one, otherwise tells how long to wait for the
+// next, a negative wait if there will be none.
+func (b *TokenBucket) take() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFill = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if b.rate <= 0 {
+		// never refills, (1-tokens)/rate would be an infinite wait
+		return -1, false
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+}
+
+func (b *TokenBucket) Acquire(ctx context.Context) (func(), error) {
+	start := b.stats.startWait(b.clock)
+
+	var err error
+	for {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		wait, ok := b.take()
+		if ok {
+			break
+		}
+		if wait < 0 {
+			<-ctx.Done()
+			continue
+		}
+
+		timer := b.clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	b.stats.endWait(b.clock, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {}, nil
+}
+
+func (b *TokenBucket) Stats() LimiterStats {
+	return b.stats.snapshot()
+}
+
+// Exclusive is implemented by signers that must not run concurrently with
+// themselves, like DataSignerMd5 that overheats.
+type Exclusive interface {
+	Exclusive() bool
+}
+
+func isExclusive(s Signer) bool {
+	exclusive, ok := s.(Exclusive)
+	return ok && exclusive.Exclusive()
+}
+
+var (
+	exclusiveMu       sync.Mutex
+	exclusiveLimiters = make(map[string]Limiter)
+)
+
+// exclusiveLimiter is the Semaphore of 1 shared by all callers of the exclusive
+// signer with the given name. DataSignerMd5 overheats on a global flag, so a
+// semaphore per pipeline wouldn't keep two pipelines from calling it at once.
+func exclusiveLimiter(name string) Limiter {
+	exclusiveMu.Lock()
+	defer exclusiveMu.Unlock()
+
+	limiter, ok := exclusiveLimiters[name]
+	if !ok {
+		limiter = NewSemaphore(1)
+		exclusiveLimiters[name] = limiter
+	}
+	return limiter
+}
+
+type limitedSigner struct {
+	Signer
+	limiter Limiter
+}
+
+func (s limitedSigner) Sign(ctx context.Context, data string) (string, error) {
+	_, finishWait := startSpan(ctx, "wait", nil)
+	release, err := s.limiter.Acquire(ctx)
+	finishWait(err)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return s.Signer.Sign(ctx, data)
+}
+
+func (s limitedSigner) cacheKey() string {
+	return signerCacheKey(s.Signer)
+}
+
+// Limited makes every call of s wait for limiter first.
+func Limited(s Signer, limiter Limiter) Signer {
+	return limitedSigner{Signer: s, limiter: limiter}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	sem := NewSemaphore(2)
+
+	var inside, maxInside int32
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := sem.Acquire(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer release()
+
+			curr := atomic.AddInt32(&inside, 1)
+			for {
+				prev := atomic.LoadInt32(&maxInside)
+				if curr <= prev || atomic.CompareAndSwapInt32(&maxInside, prev, curr) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inside, -1)
+		}()
+	}
+	wg.Wait()
+
+	if maxInside != 2 {
+		t.Errorf("expected at most 2 concurrent holders, got %d", maxInside)
+	}
+
+	stats := sem.Stats()
+	if stats.Acquired != 10 || stats.Waiting != 0 || stats.MaxWait < 10*time.Millisecond {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSemaphoreCancel(t *testing.T) {
+	sem := NewSemaphore(1)
+	release, _ := sem.Acquire(context.Background())
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := sem.Acquire(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if stats := sem.Stats(); stats.Cancelled != 1 || stats.Acquired != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	// 2 сразу из запаса, дальше по одному раз в 10мс
+	clock := NewFakeClock(fakeEpoch)
+	bucket := NewTokenBucketWithClock(100, 2, clock)
+
+	for i := 0; i < 2; i++ {
+		if wait, ok := bucket.take(); !ok || wait != 0 {
+			t.Fatalf("expected token %d from burst, got wait %s", i+1, wait)
+		}
+	}
+	if wait, ok := bucket.take(); ok || wait != 10*time.Millisecond {
+		t.Errorf("unexpected wait\nGot: %s\nExpected: %s", wait, 10*time.Millisecond)
+	}
+
+	clock.Advance(4 * time.Millisecond)
+	if wait, ok := bucket.take(); ok || wait != 6*time.Millisecond {
+		t.Errorf("unexpected wait\nGot: %s\nExpected: %s", wait, 6*time.Millisecond)
+	}
+
+	// запас не копится больше burst
+	clock.Advance(time.Second)
+	for i := 0; i < 2; i++ {
+		release, err := bucket.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		release()
+	}
+	if stats := bucket.Stats(); stats.Acquired != 2 || stats.Waiting != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if wait, ok := bucket.take(); ok || wait <= 0 {
+		t.Errorf("expected empty bucket, got wait %s", wait)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := bucket.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancel error, got %v", err)
+	}
+}
+
+// timerCounter считает таймеры, заведённые через clock
+type timerCounter struct {
+	Clock
+	timers int32
+}
+
+func (c *timerCounter) NewTimer(d time.Duration) Timer {
+	atomic.AddInt32(&c.timers, 1)
+	return c.Clock.NewTimer(d)
+}
+
+func TestTokenBucketZeroRate(t *testing.T) {
+	clock := &timerCounter{Clock: NewFakeClock(fakeEpoch)}
+	bucket := NewTokenBucketWithClock(0, 1, clock)
+
+	release, err := bucket.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+
+	// запас потрачен и не пополняется: ждать можно только отмены ctx, без таймеров
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := bucket.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if timers := atomic.LoadInt32(&clock.timers); timers != 0 {
+		t.Errorf("expected no timers, got %d", timers)
+	}
+}
+
+// overheatingSigner ломается, если его вызвать параллельно
+type overheatingSigner struct {
+	busy       int32
+	overheated int32
+}
+
+func (s *overheatingSigner) Name() string    { return "overheating" }
+func (s *overheatingSigner) Exclusive() bool { return true }
+
+func (s *overheatingSigner) Sign(ctx context.Context, data string) (string, error) {
+	if !atomic.CompareAndSwapInt32(&s.busy, 0, 1) {
+		atomic.AddInt32(&s.overheated, 1)
+		return "", errors.New("overheat")
+	}
+	defer atomic.StoreInt32(&s.busy, 0)
+
+	time.Sleep(time.Millisecond)
+	return Md5Signer{}.Sign(ctx, data)
+}
+
+func TestSignerPipelineExclusiveSigner(t *testing.T) {
+	digest := &overheatingSigner{}
+	limiter := NewTokenBucket(10000, 100)
+	p := SignerPipeline(SignerConfig{Digest: digest, Checksum: Crc32Signer{}, ChecksumLimiter: limiter})
+
+	_, err := p.Collect(context.Background(), "0", "1", "2", "3", "4", "5", "6", "7")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if digest.overheated != 0 {
+		t.Errorf("exclusive signer called concurrently %d times", digest.overheated)
+	}
+	// 2 вызова в SingleHash и 6 в MultiHash на каждое значение
+	if stats := limiter.Stats(); stats.Acquired != 8*8 {
+		t.Errorf("checksum limiter not applied: %+v", stats)
+	}
+}
+
+func TestLegacyMd5SharedLimiter(t *testing.T) {
+	lockOrig := OverheatLock
+	t.Cleanup(func() { OverheatLock = lockOrig })
+
+	var overheated int32
+	OverheatLock = func() {
+		for !atomic.CompareAndSwapUint32(&dataSignerOverheat, 0, 1) {
+			atomic.AddInt32(&overheated, 1)
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// каждый вызов строит свои подписи, но DataSignerMd5 у них общий
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg := SignerConfig{Checksum: Crc32Signer{}}
+			ok, err := VerifySingleHash(context.Background(), cfg, "0", "4108050209~502633748")
+			if !ok || err != nil {
+				t.Errorf("unexpected result: %v, %v", ok, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if overheated != 0 {
+		t.Errorf("DataSignerMd5 called concurrently %d times", overheated)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+)
+
+type cliArgs struct {
+	path     string
+	pipeline PipelineConfig
+	// cfg is the signer config of pipeline, with the metrics and the tracer of the run.
+	cfg SignerConfig
+	// listen is the address to serve SignService on instead of signing the input.
+	listen string
+	// metrics is the address to serve the stage metrics on.
+	metrics string
+	// trace is the file to write the Chrome trace of the run to.
+	trace string
+}
+
+func parseArgs(args []string) (cliArgs, error) {
+	parsed := cliArgs{
+		path:     "-",
+		pipeline: PipelineConfig{Stages: DefaultStages()},
+	}
+
+	// the config file goes first, so the flags override it wherever they are
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--config=") {
+			var err error
+			parsed.pipeline, err = ReadPipelineConfig(strings.TrimPrefix(arg, "--config="))
+			if err != nil {
+				return parsed, err
+			}
+		}
+	}
+
+	workers := 0
+	paths := make([]string, 0, 1)
+	for _, arg := range args {
+		var err error
+		switch {
+		case strings.HasPrefix(arg, "--config="):
+		case strings.HasPrefix(arg, "--stages="):
+			parsed.pipeline.Stages = nil
+			for _, name := range strings.Split(strings.TrimPrefix(arg, "--stages="), ",") {
+				parsed.pipeline.Stages = append(parsed.pipeline.Stages, StageConfig{Name: name})
+			}
+		case strings.HasPrefix(arg, "--salt="):
+			parsed.pipeline.Salt = strings.TrimPrefix(arg, "--salt=")
+		case strings.HasPrefix(arg, "--digest="):
+			parsed.pipeline.Digest = strings.TrimPrefix(arg, "--digest=")
+		case strings.HasPrefix(arg, "--checksum="):
+			parsed.pipeline.Checksum = strings.TrimPrefix(arg, "--checksum=")
+		case strings.HasPrefix(arg, "--key="):
+			parsed.pipeline.Key = strings.TrimPrefix(arg, "--key=")
+		case strings.HasPrefix(arg, "--workers="):
+			workers, err = strconv.Atoi(strings.TrimPrefix(arg, "--workers="))
+			if err != nil || workers <= 0 {
+				return parsed, fmt.Errorf("bad workers %q", arg)
+			}
+		case arg == "--ordered":
+			parsed.pipeline.Ordered = true
+		case strings.HasPrefix(arg, "--listen="):
+			parsed.listen = strings.TrimPrefix(arg, "--listen=")
+		case strings.HasPrefix(arg, "--metrics="):
+			parsed.metrics = strings.TrimPrefix(arg, "--metrics=")
+			parsed.cfg.Metrics = NewPipelineMetrics(nil)
+		case strings.HasPrefix(arg, "--trace="):
+			parsed.trace = strings.TrimPrefix(arg, "--trace=")
+			parsed.cfg.Tracer = NewTracer(nil)
+		case strings.HasPrefix(arg, "--") && arg != "--":
+			err = fmt.Errorf("unknown flag %q", arg)
+		default:
+			paths = append(paths, arg)
+		}
+		if err != nil {
+			return parsed, err
+		}
+	}
+
+	if len(paths) > 1 {
+		return parsed, fmt.Errorf("expected at most one input file")
+	}
+	if len(paths) == 1 {
+		parsed.path = paths[0]
+	}
+
+	if workers > 0 {
+		for i := range parsed.pipeline.Stages {
+			parsed.pipeline.Stages[i].Workers = workers
+		}
+	}
+
+	// per item hashes are printed in input order, so every line matches its input
+	stages := parsed.pipeline.Stages
+	if len(stages) > 0 && stages[len(stages)-1].Name != stageCombineResults {
+		parsed.pipeline.Ordered = true
+	}
+
+	_, err := parsed.pipeline.Build(parsed.cfg)
+	if err != nil {
+		return parsed, err
+	}
+	parsed.cfg, err = parsed.pipeline.SignerConfig(parsed.cfg)
+	return parsed, err
+}
+
+// signLines streams the lines of r through the pipeline of args as they are
+// read and prints every result as a line of w.
+func signLines(ctx context.Context, r io.Reader, w io.Writer, args cliArgs) error {
+	p, err := args.pipeline.Build(args.cfg)
+	if err != nil {
+		return err
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	in := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(in)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if !sendItem(ctx, in, scanner.Text()) {
+				break
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	err = p.Run(ctx, in, func(result string) error {
+		_, err := fmt.Fprintln(w, result)
+		return err
+	})
+	cancel()
+
+	select {
+	case scanErr := <-readErr:
+		if err == nil {
+			err = scanErr
+		}
+	default:
+		// the reader is stuck in a read that can't be interrupted, like one of
+		// stdin, so it is left to exit with the process
+		if err == nil {
+			err = parent.Err()
+		}
+	}
+	return err
+}
+
+// serve runs SignService on addr until ctx is done.
+func serve(ctx context.Context, addr string, cfg SignerConfig) error {
+	service := NewSignService(cfg)
+	defer service.Close()
+
+	server := &http.Server{Addr: addr, Handler: service.Handler()}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// serveMetrics serves MetricsHandler of m on addr in the background. It
+// listens right away, so a bad or busy addr fails the start of the run.
+func serveMetrics(addr string, m *PipelineMetrics) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+
+	go http.Serve(listener, MetricsHandler(m))
+	return listener.Addr(), nil
+}
+
+func writeTrace(path string, tracer *Tracer) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = tracer.WriteChromeTrace(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func main() {
+	args, err := parseArgs(os.Args[1:])
+	if err != nil {
+		panic("usage go run . [file] [--config=pipeline.yaml] [--stages=SingleHash,MultiHash,CombineResults] [--salt=salt] " +
+			"[--digest=md5] [--checksum=crc32] [--key=hmac key] [--workers=N] [--ordered] [--listen=addr] [--metrics=addr] [--trace=file]: " + err.Error())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		// the first Ctrl-C stops the run gracefully, the second one kills the process
+		<-ctx.Done()
+		stop()
+	}()
+
+	if args.metrics != "" {
+		_, err = serveMetrics(args.metrics, args.cfg.Metrics)
+		if err != nil {
+			panic(err.Error())
+		}
+		args.cfg.Metrics.Publish("signer")
+	}
+
+	if args.listen != "" {
+		err = serve(ctx, args.listen, args.cfg)
+		if err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+
+	in := io.Reader(os.Stdin)
+	if args.path != "-" {
+		file, err := os.Open(args.path)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer file.Close()
+		in = file
+	}
+
+	err = signLines(ctx, in, os.Stdout, args)
+	// Ctrl-C is a normal way to end a run on stdin
+	if err != nil && !(ctx.Err() != nil && errors.Is(err, context.Canceled)) {
+		panic(err.Error())
+	}
+
+	if args.trace != "" {
+		err = writeTrace(args.trace, args.cfg.Tracer)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the processing latency histogram.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// StageStats is a snapshot of the metrics of one stage.
+type StageStats struct {
+	Stage    string
+	ItemsIn  uint64
+	ItemsOut uint64
+	// Failed items returned an error from the item function.
+	Failed uint64
+	// QueueLen and QueueCap describe the input channel of the stage.
+	QueueLen    int
+	QueueCap    int
+	Workers     int
+	BusyWorkers int64
+	// Utilization is the share of the stage uptime its workers were busy, 0..1.
+	Utilization float64
+	// LatencyCounts[i] counts items processed within LatencyBuckets[i] and not
+	// within the previous bucket, the last one counts the slower items.
+	LatencyCounts []uint64
+	LatencySum    time.Duration
+}
+
+type stageMetrics struct {
+	name  string
+	clock Clock
+
+	itemsIn    uint64
+	itemsOut   uint64
+	failed     uint64
+	busy       int64
+	busyTime   int64
+	latency    []uint64
+	latencySum int64
+
+	mu      sync.Mutex
+	workers int
+	queue   func() (int, int)
+	started time.Time
+	stopped time.Time
+	running int
+}
+
+// PipelineMetrics collects the metrics of instrumented stages by stage name.
+// Methods of a nil *PipelineMetrics do nothing, so it can stay optional.
+type PipelineMetrics struct {
+	clock  Clock
+	mu     sync.Mutex
+	stages []*stageMetrics
+}
+
+// NewPipelineMetrics measures time with clock, SystemClock if nil.
+func NewPipelineMetrics(clock Clock) *PipelineMetrics {
+	return &PipelineMetrics{clock: clockOrSystem(clock)}
+}
+
+func (m *PipelineMetrics) stage(name string) *stageMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.stages {
+		if s.name == name {
+			return s
+		}
+	}
+
+	s := &stageMetrics{name: name, clock: m.clock, latency: make([]uint64, len(LatencyBuckets)+1)}
+	m.stages = append(m.stages, s)
+	return s
+}
+
+func (s *stageMetrics) start(workers int, queue func() (int, int)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running == 0 && s.started.IsZero() {
+		s.started = s.clock.Now()
+	}
+	s.running++
+	s.workers = workers
+	s.queue = queue
+}
+
+func (s *stageMetrics) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running--
+	if s.running == 0 {
+		s.stopped = s.clock.Now()
+	}
+}
+
+func (s *stageMetrics) observe(d time.Duration, err error) {
+	if err != nil {
+		atomic.AddUint64(&s.failed, 1)
+	}
+
+	bucket := 0
+	for bucket < len(LatencyBuckets) && d > LatencyBuckets[bucket] {
+		bucket++
+	}
+	atomic.AddUint64(&s.latency[bucket], 1)
+	atomic.AddInt64(&s.latencySum, int64(d))
+	atomic.AddInt64(&s.busyTime, int64(d))
+}
+
+func (s *stageMetrics) snapshot() StageStats {
+	stats := StageStats{
+		Stage:         s.name,
+		ItemsIn:       atomic.LoadUint64(&s.itemsIn),
+		ItemsOut:      atomic.LoadUint64(&s.itemsOut),
+		Failed:        atomic.LoadUint64(&s.failed),
+		BusyWorkers:   atomic.LoadInt64(&s.busy),
+		LatencyCounts: make([]uint64, len(s.latency)),
+		LatencySum:    time.Duration(atomic.LoadInt64(&s.latencySum)),
+	}
+	for i := range s.latency {
+		stats.LatencyCounts[i] = atomic.LoadUint64(&s.latency[i])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats.Workers = s.workers
+	if s.queue != nil && s.running > 0 {
+		stats.QueueLen, stats.QueueCap = s.queue()
+	}
+
+	end := s.stopped
+	if s.running > 0 {
+		end = s.clock.Now()
+	}
+	uptime := end.Sub(s.started)
+	if !s.started.IsZero() && uptime > 0 && s.workers > 0 {
+		stats.Utilization = float64(atomic.LoadInt64(&s.busyTime)) / float64(uptime) / float64(s.workers)
+	}
+
+	return stats
+}
+
+// Stats returns the metrics of every stage in the order the stages were instrumented.
+func (m *PipelineMetrics) Stats() []StageStats {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	stages := append([]*stageMetrics(nil), m.stages...)
+	m.mu.Unlock()
+
+	stats := make([]StageStats, 0, len(stages))
+	for _, s := range stages {
+		stats = append(stats, s.snapshot())
+	}
+	return stats
+}
+
+// InstrumentStage counts the items going in and out of stage and watches the
+// occupancy of its input channel. workers is reported as is, 0 means DefaultWorkers.
+func InstrumentStage[In, Out any](m *PipelineMetrics, name string, workers int, stage Stage[In, Out]) Stage[In, Out] {
+	if m == nil {
+		return stage
+	}
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	s := m.stage(name)
+
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		s.start(workers, func() (int, int) { return len(in), cap(in) })
+		defer s.stop()
+
+		count := func(_ context.Context, item In) (In, error) {
+			atomic.AddUint64(&s.itemsIn, 1)
+			return item, nil
+		}
+		return pumpInput(ctx, in, out, count, func(ctx context.Context, counted <-chan In, out chan<- Out) error {
+			return pumpOutput(ctx, counted, stage, func(item Out) {
+				if sendItem(ctx, out, item) {
+					atomic.AddUint64(&s.itemsOut, 1)
+				}
+			})
+		})
+	}
+}
+
+// MeasureItems records the latency, failures and busy workers of the item
+// function of stage name.
+func MeasureItems[In, Out any](m *PipelineMetrics, name string, fn func(ctx context.Context, item In) (Out, error)) func(ctx context.Context, item In) (Out, error) {
+	if m == nil {
+		return fn
+	}
+	s := m.stage(name)
+
+	return func(ctx context.Context, item In) (Out, error) {
+		atomic.AddInt64(&s.busy, 1)
+		defer atomic.AddInt64(&s.busy, -1)
+
+		start := s.clock.Now()
+		result, err := fn(ctx, item)
+		s.observe(s.clock.Now().Sub(start), err)
+		return result, err
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format.
+func (m *PipelineMetrics) WritePrometheus(w io.Writer) error {
+	stats := m.Stats()
+	text := &strings.Builder{}
+
+	metric := func(name, kind, help string, value func(s StageStats) float64) {
+		fmt.Fprintf(text, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, s := range stats {
+			fmt.Fprintf(text, "%s{stage=%q} %s\n", name, s.Stage, strconv.FormatFloat(value(s), 'g', -1, 64))
+		}
+	}
+
+	metric("signer_stage_items_in_total", "counter", "Items read by the stage.",
+		func(s StageStats) float64 { return float64(s.ItemsIn) })
+	metric("signer_stage_items_out_total", "counter", "Items written by the stage.",
+		func(s StageStats) float64 { return float64(s.ItemsOut) })
+	metric("signer_stage_items_failed_total", "counter", "Items the stage failed to process.",
+		func(s StageStats) float64 { return float64(s.Failed) })
+	metric("signer_stage_queue_length", "gauge", "Items waiting in the input channel of the stage.",
+		func(s StageStats) float64 { return float64(s.QueueLen) })
+	metric("signer_stage_queue_capacity", "gauge", "Capacity of the input channel of the stage.",
+		func(s StageStats) float64 { return float64(s.QueueCap) })
+	metric("signer_stage_workers", "gauge", "Workers of the stage.",
+		func(s StageStats) float64 { return float64(s.Workers) })
+	metric("signer_stage_busy_workers", "gauge", "Workers processing an item.",
+		func(s StageStats) float64 { return float64(s.BusyWorkers) })
+	metric("signer_stage_utilization", "gauge", "Share of the uptime the workers were busy.",
+		func(s StageStats) float64 { return s.Utilization })
+
+	name := "signer_stage_item_duration_seconds"
+	fmt.Fprintf(text, "# HELP %s Processing latency of an item.\n# TYPE %s histogram\n", name, name)
+	for _, s := range stats {
+		var cumulative uint64
+		for i, count := range s.LatencyCounts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(LatencyBuckets) {
+				le = seconds(LatencyBuckets[i])
+			}
+			fmt.Fprintf(text, "%s_bucket{stage=%q,le=%q} %d\n", name, s.Stage, le, cumulative)
+		}
+		fmt.Fprintf(text, "%s_sum{stage=%q} %s\n", name, s.Stage, seconds(s.LatencySum))
+		fmt.Fprintf(text, "%s_count{stage=%q} %d\n", name, s.Stage, cumulative)
+	}
+
+	_, err := io.WriteString(w, text.String())
+	return err
+}
+
+// Publish exposes Stats as the expvar variable name. Like expvar.Publish it
+// panics if name is already taken.
+func (m *PipelineMetrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return m.Stats() }))
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format on /metrics
+// and the expvar variables on /debug/vars.
+func MetricsHandler(m *PipelineMetrics) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WritePrometheus(w)
+	})
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stagesStarted(metrics *PipelineMetrics, count int) bool {
+	stats := metrics.Stats()
+	for _, s := range stats {
+		if s.Workers == 0 {
+			return false
+		}
+	}
+	return len(stats) == count
+}
+
+func TestSignerPipelineMetrics(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	metrics := NewPipelineMetrics(clock)
+	cfg := SignerConfig{
+		Digest:           Md5Signer{},
+		Checksum:         Delayed(Crc32Signer{}, time.Second, clock),
+		DigestLimiter:    NewSemaphoreWithClock(1, clock),
+		MultiHashWorkers: 7,
+		Metrics:          metrics,
+	}
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err = SignerPipeline(cfg).Collect(context.Background(), "0", "1", "1", "2", "3", "5", "8")
+	}()
+	// стадия, запущенная после сдвига часов, насчитала бы себе меньше времени работы
+	for !stagesStarted(metrics, 3) {
+		time.Sleep(time.Millisecond)
+	}
+	// md5 мгновенный: все значения проходят SingleHash за секунду и MultiHash ещё за одну
+	driveClock(t, clock, clockStep{2 * 7, time.Second}, clockStep{6 * 7, time.Second})
+	<-done
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := metrics.Stats()
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 stages, got %+v", stats)
+	}
+
+	expected := []struct {
+		stage    string
+		in, out  uint64
+		workers  int
+		measured uint64
+	}{
+		{"SingleHash", 7, 7, DefaultWorkers, 7},
+		{"MultiHash", 7, 7, 7, 7},
+		{"CombineResults", 7, 1, 1, 0},
+	}
+	for i, e := range expected {
+		s := stats[i]
+		var measured uint64
+		for _, count := range s.LatencyCounts {
+			measured += count
+		}
+		if s.Stage != e.stage || s.ItemsIn != e.in || s.ItemsOut != e.out || s.Workers != e.workers || measured != e.measured {
+			t.Errorf("stats not match\nGot: %+v\nExpected: %+v", s, e)
+		}
+		if s.BusyWorkers != 0 || s.QueueLen != 0 || s.Failed != 0 {
+			t.Errorf("stage %s not idle: %+v", s.Stage, s)
+		}
+	}
+
+	// MultiHash: 6 crc32 параллельно, ровно секунда на значение
+	multi := stats[1]
+	if multi.LatencyCounts[6] != 7 || multi.LatencySum != 7*time.Second {
+		t.Errorf("unexpected MultiHash latency %v, sum %s", multi.LatencyCounts, multi.LatencySum)
+	}
+	// все 7 воркеров MultiHash заняты по секунде из 2 секунд работы стадии
+	if multi.Utilization < 0.45 || multi.Utilization > 0.55 {
+		t.Errorf("unexpected MultiHash utilization %f", multi.Utilization)
+	}
+
+	server := httptest.NewServer(MetricsHandler(metrics))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	for _, line := range []string{
+		"# TYPE signer_stage_items_in_total counter",
+		`signer_stage_items_in_total{stage="CombineResults"} 7`,
+		`signer_stage_items_out_total{stage="CombineResults"} 1`,
+		`signer_stage_workers{stage="MultiHash"} 7`,
+		`signer_stage_item_duration_seconds_bucket{stage="MultiHash",le="0.5"} 0`,
+		`signer_stage_item_duration_seconds_bucket{stage="MultiHash",le="1"} 7`,
+		`signer_stage_item_duration_seconds_bucket{stage="MultiHash",le="+Inf"} 7`,
+		`signer_stage_item_duration_seconds_sum{stage="MultiHash"} 7`,
+		`signer_stage_item_duration_seconds_count{stage="MultiHash"} 7`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("metrics miss line %q\n%s", line, body)
+		}
+	}
+}
+
+func TestInstrumentStageQueue(t *testing.T) {
+	metrics := NewPipelineMetrics(nil)
+	release := make(chan struct{})
+	stage := InstrumentStage(metrics, "slow", 1, func(ctx context.Context, in <-chan int, out chan<- int) error {
+		<-release
+		for item := range in {
+			out <- item
+		}
+		return nil
+	})
+
+	in := make(chan int, 5)
+	for i := 0; i < 3; i++ {
+		in <- i
+	}
+	close(in)
+
+	done := make(chan error)
+	go func() {
+		done <- NewPipeline("slow", stage).Run(context.Background(), in, func(int) error { return nil })
+	}()
+
+	// стадия ещё не читает: одно значение забрала считающая горутина, два ждут в канале
+	deadline := time.Now().Add(time.Second)
+	for metrics.Stats()[0].QueueLen != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if s := metrics.Stats()[0]; s.QueueLen != 2 || s.QueueCap != 5 || s.ItemsIn != 1 {
+		t.Errorf("unexpected queue stats %+v", s)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if s := metrics.Stats()[0]; s.ItemsIn != 3 || s.ItemsOut != 3 || s.QueueLen != 0 {
+		t.Errorf("unexpected stats after the run %+v", s)
+	}
+}
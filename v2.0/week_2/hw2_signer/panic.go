@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrSkipItem returned by the item function of ParallelStage or
+// OrderedParallelStage drops the item without failing the stage.
+var ErrSkipItem = errors.New("item skipped")
+
+// PanicError is a recovered panic of a job, a stage or an item function.
+type PanicError struct {
+	Value interface{}
+	// Item is the item being processed, nil for panics of whole jobs and stages.
+	Item  interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if e.Item != nil {
+		return fmt.Sprintf("panic on item %v: %v", e.Item, e.Value)
+	}
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap makes errors passed to panic visible to errors.Is and errors.As.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// catchPanic turns a panic of the deferring function into a PanicError in err.
+// It has to be deferred directly.
+func catchPanic(item interface{}, err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Item: item, Stack: debug.Stack()}
+	}
+}
+
+// safeCall calls fn, a panic of fn is returned as a PanicError carrying item.
+func safeCall[In, Out any](ctx context.Context, fn func(ctx context.Context, item In) (Out, error), item In) (result Out, err error) {
+	defer catchPanic(item, &err)
+	return fn(ctx, item)
+}
+
+// PanicPolicy decides what a stage does with an item whose function panicked.
+type PanicPolicy int
+
+const (
+	// PanicAbort fails the stage with the PanicError.
+	PanicAbort PanicPolicy = iota
+	// PanicSkip drops the item and keeps the stage running.
+	PanicSkip
+)
+
+// OnPanic applies policy to the panics of fn.
+func OnPanic[In, Out any](policy PanicPolicy, fn func(ctx context.Context, item In) (Out, error)) func(ctx context.Context, item In) (Out, error) {
+	if policy != PanicSkip {
+		return fn
+	}
+
+	return func(ctx context.Context, item In) (Out, error) {
+		result, err := safeCall(ctx, fn, item)
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) {
+			return result, fmt.Errorf("%w: %w", ErrSkipItem, err)
+		}
+		return result, err
+	}
+}
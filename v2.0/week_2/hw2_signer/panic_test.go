@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+var errBoom = errors.New("boom")
+
+// panickySigner паникует на значении on, остальное подписывает как Signer.
+type panickySigner struct {
+	Signer
+	on string
+}
+
+func (s panickySigner) Sign(ctx context.Context, data string) (string, error) {
+	if data == s.on {
+		panic(errBoom)
+	}
+	return s.Signer.Sign(ctx, data)
+}
+
+func TestExecutePipelinePanic(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	var recieved []interface{}
+	jobs := []job{
+		job(func(in, out chan interface{}) {
+			for i := 0; i < 5; i++ {
+				out <- i
+			}
+		}),
+		job(func(in, out chan interface{}) {
+			<-in
+			panic(errBoom)
+		}),
+		job(func(in, out chan interface{}) {
+			for item := range in {
+				recieved = append(recieved, item)
+			}
+		}),
+	}
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		ExecutePipeline(jobs...)
+	}()
+
+	panicErr, ok := recovered.(*PanicError)
+	if !ok || !errors.Is(panicErr, errBoom) || len(panicErr.Stack) == 0 {
+		t.Fatalf("expected *PanicError with stack, got %#v", recovered)
+	}
+	if len(recieved) != 0 {
+		t.Errorf("expected nothing after the panicking job, got %v", recieved)
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestExecutePipelinePanicAbortsRun(t *testing.T) {
+	var recieved []interface{}
+	jobs := []job{
+		job(func(in, out chan interface{}) {
+			out <- 0
+			out <- 1
+			out <- "bad"
+		}),
+		job(SingleHash),
+		job(MultiHash),
+		job(CombineResults),
+		job(func(in, out chan interface{}) {
+			for item := range in {
+				recieved = append(recieved, item)
+			}
+		}),
+	}
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		ExecutePipeline(jobs...)
+	}()
+
+	panicErr, ok := recovered.(*PanicError)
+	if !ok || !errors.Is(panicErr, ErrUnexpectedItem) {
+		t.Fatalf("expected *PanicError with ErrUnexpectedItem, got %#v", recovered)
+	}
+	// CombineResults не должен отдать результат по неполному входу
+	if len(recieved) != 0 {
+		t.Errorf("expected nothing after the panicking job, got %q", recieved)
+	}
+}
+
+func TestExecutePipelineContextPanic(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	jobs := []jobCtx{
+		func(ctx context.Context, in, out chan interface{}) {
+			send(ctx, out, "not an int")
+		},
+		SingleHashContext,
+		MultiHashContext,
+		CombineResultsContext,
+	}
+
+	err := ExecutePipelineContext(context.Background(), jobs...)
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || !errors.Is(err, ErrUnexpectedItem) {
+		t.Errorf("expected panic with ErrUnexpectedItem, got %v", err)
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestExecutePipelineErrPanic(t *testing.T) {
+	jobs := []jobErr{
+		func(ctx context.Context, in, out chan interface{}) error {
+			send(ctx, out, 0)
+			<-ctx.Done()
+			return nil
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			panic("boom")
+		},
+	}
+
+	err := ExecutePipelineErr(context.Background(), jobs...)
+	if err == nil || err.Error() != "stage 1: panic: boom" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParallelStagePanic(t *testing.T) {
+	stage := ParallelStage(2, func(_ context.Context, num int) (int, error) {
+		if num == 3 {
+			panic(errBoom)
+		}
+		return num, nil
+	})
+
+	_, err := NewPipeline("panicky", stage).Collect(context.Background(), 1, 2, 3, 4)
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Item != 3 {
+		t.Fatalf("expected panic on item 3, got %v", err)
+	}
+	if err.Error() != "panicky: panic on item 3: boom" {
+		t.Errorf("unexpected error text: %v", err)
+	}
+}
+
+func TestSignerPipelinePanicPolicy(t *testing.T) {
+	cfg := SignerConfig{
+		Digest:   Md5Signer{},
+		Checksum: panickySigner{Signer: Crc32Signer{}, on: "1"},
+	}
+
+	_, err := SignerPipeline(cfg).Collect(context.Background(), "0", "1", "2")
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Item != "1" {
+		t.Errorf("expected panic on item 1, got %v", err)
+	}
+
+	for _, ordered := range []bool{false, true} {
+		cfg.Ordered = ordered
+		cfg.OnPanic = PanicSkip
+		results, err := SignerPipeline(cfg).Collect(context.Background(), "0", "1", "2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected, _ := SignerPipeline(SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}, Ordered: ordered}).Collect(context.Background(), "0", "2")
+		if len(results) != 1 || results[0] != expected[0] {
+			t.Errorf("results not match\nGot: %v\nExpected: %v", results, expected)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+)
+
+// jobCtx is a job that has to stop as soon as ctx is done.
+type jobCtx func(ctx context.Context, in, out chan interface{})
+
+// withContext adapts a plain job to ExecutePipelineContext. The job itself
+// knows nothing about ctx, it stops once its input is closed.
+func withContext(j job) jobCtx {
+	return func(_ context.Context, in, out chan interface{}) {
+		j(in, out)
+	}
+}
+
+func drain(ch chan interface{}) {
+	for range ch {
+	}
+}
+
+// receive reads the next item from in, ok is false if in is closed or ctx is done.
+func receive(ctx context.Context, in chan interface{}) (interface{}, bool) {
+	return receiveItem[interface{}](ctx, in)
+}
+
+// send writes item to out, returns false if ctx is done before out accepts it.
+func send(ctx context.Context, out chan interface{}, item interface{}) bool {
+	return sendItem[interface{}](ctx, out, item)
+}
+
+func jobCtxWithWg(ctx context.Context, j jobCtx, in, out chan interface{}, wg *sync.WaitGroup, onPanic func(error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			onPanic(&PanicError{Value: r, Stack: debug.Stack()})
+		}
+		close(out)
+		// nobody else reads in, so drain it to let the previous job finish its sends
+		drain(in)
+		wg.Done()
+	}()
+	j(ctx, in, out)
+}
+
+// ExecutePipelineContext runs jobs like ExecutePipeline but stops them when ctx
+// is cancelled or its deadline passes. Every stage drains its input after it
+// returns, so upstream jobs never block on a stage that quit early, and the
+// call returns only after all goroutines it started have exited. The only
+// exception are signer calls that ignore ctx, like DataSignerMd5: the hash
+// stages abandon them and they finish in the background.
+// A panicking job cancels the others and its *PanicError is returned.
+func ExecutePipelineContext(ctx context.Context, jobs ...jobCtx) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		panicErr error
+	)
+	onPanic := func(err error) {
+		once.Do(func() {
+			panicErr = err
+			cancel()
+		})
+	}
+
+	in := make(chan interface{}, MaxInputDataLen)
+	close(in)
+
+	wg := &sync.WaitGroup{}
+
+	for _, job := range jobs {
+		out := make(chan interface{}, MaxInputDataLen)
+		wg.Add(1)
+		go jobCtxWithWg(runCtx, job, in, out, wg, onPanic)
+		in = out
+	}
+
+	wg.Add(1)
+	go func(last chan interface{}) {
+		defer wg.Done()
+		drain(last)
+	}(in)
+
+	wg.Wait()
+
+	if panicErr != nil {
+		return panicErr
+	}
+
+	return ctx.Err()
+}
+
+// panicOnErr keeps the jobCtx contract of the signer stages: a bad item panics
+// like it does in SingleHash and ExecutePipelineContext returns it as a
+// *PanicError, use the *Err stages with ExecutePipelineErr instead.
+func panicOnErr(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func SingleHashContext(ctx context.Context, in, out chan interface{}) {
+	panicOnErr(SingleHashErr(ctx, in, out))
+}
+
+func MultiHashContext(ctx context.Context, in, out chan interface{}) {
+	panicOnErr(MultiHashErr(ctx, in, out))
+}
+
+func CombineResultsContext(ctx context.Context, in, out chan interface{}) {
+	panicOnErr(CombineResultsErr(ctx, in, out))
+}
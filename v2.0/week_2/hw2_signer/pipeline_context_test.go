@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// waitGoroutines waits a bit for exited goroutines to disappear from runtime
+// stats, abandoned DataSignerCrc32 calls included: they need up to a second.
+func waitGoroutines(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("goroutines leaked: before %d, after %d\n%s", before, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExecutePipelineContextDeadline(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var recieved int
+	jobs := []jobCtx{
+		// бесконечный генератор, остановить его может только ctx
+		func(ctx context.Context, in, out chan interface{}) {
+			for i := 0; send(ctx, out, i); i++ {
+			}
+		},
+		// уходит раньше времени, не дочитав вход
+		func(ctx context.Context, in, out chan interface{}) {
+			for i := 0; i < 3; i++ {
+				<-in
+				recieved++
+			}
+		},
+	}
+
+	err := ExecutePipelineContext(ctx, jobs...)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if recieved != 3 {
+		t.Errorf("expected 3 recieved items, got %d", recieved)
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestExecutePipelineContextCancelSigner(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var testResult interface{} = "NOT_SET"
+	jobs := []jobCtx{
+		func(ctx context.Context, in, out chan interface{}) {
+			for i := 0; i < 3; i++ {
+				send(ctx, out, i)
+			}
+			cancel()
+		},
+		SingleHashContext,
+		MultiHashContext,
+		CombineResultsContext,
+		func(ctx context.Context, in, out chan interface{}) {
+			if item, ok := receive(ctx, in); ok {
+				testResult = item
+			}
+		},
+	}
+
+	start := time.Now()
+	err := ExecutePipelineContext(ctx, jobs...)
+	end := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancel error, got %v", err)
+	}
+	if testResult != "NOT_SET" {
+		t.Errorf("cancelled pipeline should not produce result, got %v", testResult)
+	}
+	// уже запущенные DataSignerCrc32 дорабатывают свою секунду, но не больше
+	if end > 2*time.Second {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, 2*time.Second)
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestExecutePipelineContextLegacyJobs(t *testing.T) {
+	var recieved uint32
+	jobs := []jobCtx{
+		withContext(func(in, out chan interface{}) {
+			out <- uint32(1)
+			out <- uint32(3)
+		}),
+		withContext(func(in, out chan interface{}) {
+			for val := range in {
+				recieved += val.(uint32)
+			}
+		}),
+	}
+
+	err := ExecutePipelineContext(context.Background(), jobs...)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if recieved != 4 {
+		t.Errorf("expected 4, got %d", recieved)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnexpectedItem is returned by signer stages for items of a type they can't sign.
+var ErrUnexpectedItem = errors.New("unexpected item type")
+
+// jobErr is a job that can fail. The first failure cancels the whole pipeline.
+type jobErr func(ctx context.Context, in, out chan interface{}) error
+
+// ExecutePipelineErr runs jobs like ExecutePipelineContext. As soon as one of
+// them returns an error or panics the context of the others is cancelled, and
+// that first error is returned once every stage has exited. Stages in skip
+// mode don't fail on bad items, ExecutePipelineReport returns their counts.
+func ExecutePipelineErr(ctx context.Context, jobs ...jobErr) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+	)
+
+	ctxJobs := make([]jobCtx, 0, len(jobs))
+	for i, j := range jobs {
+		stage, j := i, j
+		ctxJobs = append(ctxJobs, func(ctx context.Context, in, out chan interface{}) {
+			err := runJobErr(ctx, j, in, out)
+			if err == nil {
+				return
+			}
+			once.Do(func() {
+				firstErr = fmt.Errorf("stage %d: %w", stage, err)
+				cancel()
+			})
+		})
+	}
+
+	err := ExecutePipelineContext(ctx, ctxJobs...)
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return err
+}
+
+func runJobErr(ctx context.Context, j jobErr, in, out chan interface{}) (err error) {
+	defer catchPanic(nil, &err)
+	return j(ctx, in, out)
+}
+
+func unexpectedItem(stage string, item interface{}) error {
+	return fmt.Errorf("%s: %w %T", stage, ErrUnexpectedItem, item)
+}
+
+func SingleHashErr(ctx context.Context, in, out chan interface{}) error {
+	return AdaptStage("SingleHash", SingleHashStage, intItem)(ctx, in, out)
+}
+
+func MultiHashErr(ctx context.Context, in, out chan interface{}) error {
+	return AdaptStage("MultiHash", MultiHashStage, stringItem)(ctx, in, out)
+}
+
+func CombineResultsErr(ctx context.Context, in, out chan interface{}) error {
+	return AdaptStage("CombineResults", CombineResultsStage, stringItem)(ctx, in, out)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestExecutePipelineErrBadItem(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	jobs := []jobErr{
+		func(ctx context.Context, in, out chan interface{}) error {
+			for _, item := range []interface{}{1, "two", 3} {
+				if !send(ctx, out, item) {
+					return nil
+				}
+			}
+			// ждём, пока SingleHashErr упадёт и отменит конвейер
+			<-ctx.Done()
+			return nil
+		},
+		SingleHashErr,
+		MultiHashErr,
+		CombineResultsErr,
+	}
+
+	start := time.Now()
+	err := ExecutePipelineErr(context.Background(), jobs...)
+	end := time.Since(start)
+
+	if !errors.Is(err, ErrUnexpectedItem) {
+		t.Errorf("expected ErrUnexpectedItem, got %v", err)
+	}
+	if err != nil && err.Error() != "stage 1: SingleHash: unexpected item type string" {
+		t.Errorf("unexpected error text: %v", err)
+	}
+	// SingleHash не ждёт DataSignerCrc32 от 1 после отмены, до MultiHash он не доходит
+	if end > 500*time.Millisecond {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, 500*time.Millisecond)
+	}
+
+	// брошенный вызов DataSignerCrc32 досчитывает свою секунду
+	waitGoroutines(t, before)
+}
+
+func TestExecutePipelineErrFirstErrorWins(t *testing.T) {
+	errFirst := errors.New("first")
+	jobs := []jobErr{
+		func(ctx context.Context, in, out chan interface{}) error {
+			<-ctx.Done()
+			return errors.New("second")
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			return errFirst
+		},
+	}
+
+	err := ExecutePipelineErr(context.Background(), jobs...)
+	if !errors.Is(err, errFirst) {
+		t.Errorf("expected first error, got %v", err)
+	}
+}
+
+func TestExecutePipelineErrOK(t *testing.T) {
+	var testResult interface{}
+	jobs := []jobErr{
+		func(ctx context.Context, in, out chan interface{}) error {
+			send(ctx, out, 0)
+			return nil
+		},
+		SingleHashErr,
+		MultiHashErr,
+		CombineResultsErr,
+		func(ctx context.Context, in, out chan interface{}) error {
+			testResult, _ = receive(ctx, in)
+			return nil
+		},
+	}
+
+	err := ExecutePipelineErr(context.Background(), jobs...)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := "29568666068035183841425683795340791879727309630931025356555"
+	if testResult != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", testResult, expected)
+	}
+}
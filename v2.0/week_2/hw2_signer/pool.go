@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultWorkers is the worker count of a stage when none is configured.
+const DefaultWorkers = MaxInputDataLen
+
+// ParallelStage runs fn over the input with a fixed pool of workers, so the
+// number of goroutines doesn't depend on the number of items. Results are
+// emitted in completion order. The first error of fn stops the pool, panics of
+// fn fail it with a PanicError and items failing with ErrSkipItem are dropped.
+func ParallelStage[In, Out any](workers int, fn func(ctx context.Context, item In) (Out, error)) Stage[In, Out] {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			wg       sync.WaitGroup
+			once     sync.Once
+			firstErr error
+		)
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					item, ok := receiveItem(ctx, in)
+					if !ok {
+						return
+					}
+
+					result, err := safeCall(ctx, fn, item)
+					if errors.Is(err, ErrSkipItem) {
+						continue
+					}
+					if err != nil {
+						once.Do(func() {
+							firstErr = err
+							cancel()
+						})
+						return
+					}
+
+					if !sendItem(ctx, out, result) {
+						return
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+		return firstErr
+	}
+}
+
+type orderedResult[Out any] struct {
+	result Out
+	err    error
+}
+
+type orderedTask[In, Out any] struct {
+	item In
+	slot chan orderedResult[Out]
+}
+
+// OrderedParallelStage is ParallelStage that emits results in input order.
+// Every item gets a result slot queued in input order, at most workers items
+// are in flight or waiting for an earlier one to complete.
+func OrderedParallelStage[In, Out any](workers int, fn func(ctx context.Context, item In) (Out, error)) Stage[In, Out] {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		tasks := make(chan orderedTask[In, Out])
+		pending := make(chan chan orderedResult[Out], workers)
+
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				close(tasks)
+				close(pending)
+				wg.Done()
+			}()
+			for {
+				item, ok := receiveItem(ctx, in)
+				if !ok {
+					return
+				}
+
+				slot := make(chan orderedResult[Out], 1)
+				if !sendItem(ctx, pending, slot) || !sendItem(ctx, tasks, orderedTask[In, Out]{item, slot}) {
+					return
+				}
+			}
+		}()
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for task := range tasks {
+					result, err := safeCall(ctx, fn, task.item)
+					task.slot <- orderedResult[Out]{result, err}
+				}
+			}()
+		}
+
+		var firstErr error
+		for slot := range pending {
+			var res orderedResult[Out]
+			select {
+			case res = <-slot:
+			case <-ctx.Done():
+				continue
+			}
+
+			if errors.Is(res.err, ErrSkipItem) {
+				continue
+			}
+			if res.err != nil {
+				if firstErr == nil {
+					firstErr = res.err
+					cancel()
+				}
+				continue
+			}
+
+			sendItem(ctx, out, res.result)
+		}
+
+		wg.Wait()
+		return firstErr
+	}
+}
+
+func parallelStage[In, Out any](workers int, ordered bool, fn func(ctx context.Context, item In) (Out, error)) Stage[In, Out] {
+	if ordered {
+		return OrderedParallelStage(workers, fn)
+	}
+	return ParallelStage(workers, fn)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useFastSigners подменяет DataSignerMd5 и DataSignerCrc32 на версии без
+// долгих time.Sleep и возвращает оригиналы после теста. onCall вызывается
+// при каждом обращении к подписи.
+func useFastSigners(t *testing.T, onCall func()) {
+	t.Helper()
+	md5Orig, crc32Orig := DataSignerMd5, DataSignerCrc32
+	t.Cleanup(func() {
+		DataSignerMd5, DataSignerCrc32 = md5Orig, crc32Orig
+	})
+
+	DataSignerMd5 = func(data string) string {
+		onCall()
+		return fmt.Sprintf("%x", md5.Sum([]byte(data)))
+	}
+	DataSignerCrc32 = func(data string) string {
+		onCall()
+		time.Sleep(time.Millisecond)
+		return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(data))), 10)
+	}
+}
+
+func maxGoroutinesForInputs(t *testing.T, inputsCount int) int64 {
+	var maxGoroutines int64
+	useFastSigners(t, func() {
+		curr := int64(runtime.NumGoroutine())
+		for {
+			prev := atomic.LoadInt64(&maxGoroutines)
+			if curr <= prev || atomic.CompareAndSwapInt64(&maxGoroutines, prev, curr) {
+				return
+			}
+		}
+	})
+
+	inputs := make([]string, inputsCount)
+	for i := range inputs {
+		inputs[i] = strconv.Itoa(i)
+	}
+
+	p := SignerPipeline(SignerConfig{SingleHashWorkers: 4, MultiHashWorkers: 2})
+	results, err := p.Collect(context.Background(), inputs...)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("unexpected result: %v, %v", results, err)
+	}
+
+	return atomic.LoadInt64(&maxGoroutines)
+}
+
+func TestParallelStageBoundedGoroutines(t *testing.T) {
+	small := maxGoroutinesForInputs(t, 10)
+	big := maxGoroutinesForInputs(t, 300)
+
+	// 4 воркера SingleHash по 3 горутины расчёта + 2 воркера MultiHash по 6
+	if big > small+2 || big-int64(runtime.NumGoroutine()) > 4*4+2*7+10 {
+		t.Errorf("goroutine count depends on input size: %d for 10 items, %d for 300 items", small, big)
+	}
+}
+
+func TestParallelStageError(t *testing.T) {
+	errOdd := errors.New("odd")
+	stage := ParallelStage(3, func(_ context.Context, num int) (int, error) {
+		if num%2 == 1 {
+			return 0, errOdd
+		}
+		return num, nil
+	})
+
+	_, err := NewPipeline("even", stage).Collect(context.Background(), 0, 2, 4, 5, 6)
+	if !errors.Is(err, errOdd) {
+		t.Errorf("expected odd error, got %v", err)
+	}
+}
+
+func TestOrderedParallelStage(t *testing.T) {
+	// чем меньше число, тем дольше оно считается - без упорядочивания порядок бы развернулся
+	stage := OrderedParallelStage(4, func(_ context.Context, num int) (int, error) {
+		time.Sleep(time.Duration(10-num) * time.Millisecond)
+		return num * num, nil
+	})
+
+	results, err := NewPipeline("square", stage).Collect(context.Background(), 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, result := range results {
+		if result != i*i {
+			t.Fatalf("results out of order: %v", results)
+		}
+	}
+	if len(results) != 10 {
+		t.Errorf("expected 10 results, got %v", results)
+	}
+}
+
+func TestOrderedParallelStageError(t *testing.T) {
+	errOdd := errors.New("odd")
+	stage := OrderedParallelStage(3, func(_ context.Context, num int) (int, error) {
+		if num%2 == 1 {
+			return 0, errOdd
+		}
+		return num, nil
+	})
+
+	results, err := NewPipeline("even", stage).Collect(context.Background(), 0, 2, 4, 5, 6)
+	if !errors.Is(err, errOdd) {
+		t.Errorf("expected odd error, got %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("items before the failed one should pass, got %v", results)
+	}
+}
+
+func TestSignerPipelineOrdered(t *testing.T) {
+	useFastSigners(t, func() {})
+
+	p := SignerPipeline(SignerConfig{SingleHashWorkers: 3, MultiHashWorkers: 3, Ordered: true})
+	results, err := p.Collect(context.Background(), "1", "0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// тот же результат что и для "0", "1", но без сортировки
+	expected := "4958044192186797981418233587017209679042592862002427381542_29568666068035183841425683795340791879727309630931025356555"
+	if len(results) != 1 || results[0] != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, expected)
+	}
+}
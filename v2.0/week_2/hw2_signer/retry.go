@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsRetryable is the default error classification of RetryPolicy: everything
+// but cancellation, bad items and errors marked Permanent may succeed next time.
+func IsRetryable(err error) bool {
+	var permanent permanentError
+	return err != nil &&
+		!isContextErr(err) &&
+		!errors.Is(err, ErrUnexpectedItem) &&
+		!errors.As(err, &permanent)
+}
+
+// RetryPolicy retries failed calls with exponential backoff and jitter.
+type RetryPolicy struct {
+	// MaxAttempts counts the first call too, 0 means 3.
+	MaxAttempts int
+	// BaseDelay is the pause after the first failure, doubled (by Multiplier) after each next one.
+	BaseDelay time.Duration
+	// MaxDelay caps every pause, 0 leaves only the cap of time.Duration itself.
+	MaxDelay   time.Duration
+	Multiplier float64
+	// Jitter shortens every pause by a random fraction of up to Jitter, 0..1.
+	Jitter float64
+	// Retryable classifies errors, IsRetryable by default.
+	Retryable func(err error) bool
+	// Clock times the pauses, SystemClock by default.
+	Clock Clock
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := float64(p.BaseDelay)
+	for i := 1; i < attempt; i++ {
+		delay *= p.Multiplier
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * p.Jitter * rand.Float64()
+	}
+	// converting a float beyond the range of time.Duration wraps it negative
+	if delay >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(delay)
+}
+
+// Do calls fn until it succeeds, fails with an error that is not retryable,
+// runs out of attempts or ctx is done.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	p = p.withDefaults()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || !p.Retryable(err) {
+			return err
+		}
+		if attempt == p.MaxAttempts {
+			return fmt.Errorf("after %d attempts: %w", attempt, err)
+		}
+
+		if sleepErr := sleepContext(ctx, p.Clock, p.delay(attempt)); sleepErr != nil {
+			return sleepErr
+		}
+	}
+}
+
+// WithRetry wraps the item function of a ParallelStage or OrderedParallelStage in policy.
+func WithRetry[In, Out any](policy RetryPolicy, fn func(ctx context.Context, item In) (Out, error)) func(ctx context.Context, item In) (Out, error) {
+	return func(ctx context.Context, item In) (Out, error) {
+		var result Out
+		err := policy.Do(ctx, func(ctx context.Context) error {
+			var err error
+			result, err = fn(ctx, item)
+			return err
+		})
+		return result, err
+	}
+}
+
+type retriedSigner struct {
+	Signer
+	policy RetryPolicy
+}
+
+func (s retriedSigner) Sign(ctx context.Context, data string) (string, error) {
+	return WithRetry(s.policy, s.Signer.Sign)(ctx, data)
+}
+
+func (s retriedSigner) cacheKey() string {
+	return signerCacheKey(s.Signer)
+}
+
+// Retried retries the failed calls of s according to policy.
+func Retried(s Signer, policy RetryPolicy) Signer {
+	return retriedSigner{Signer: s, policy: policy}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	flakyCrc32 := &countingSigner{Signer: Crc32Signer{}, fails: 2}
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5}
+
+	hash, err := Retried(flakyCrc32, policy).Sign(context.Background(), "0")
+	if err != nil || hash != "4108050209" {
+		t.Errorf("unexpected result: %v, %v", hash, err)
+	}
+	if flakyCrc32.calls != 3 {
+		t.Errorf("expected 3 calls, got %d", flakyCrc32.calls)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}.withDefaults()
+
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, delay := range expected {
+		if got := policy.delay(i + 1); got != delay {
+			t.Errorf("results not match for attempt %d\nGot: %s\nExpected: %s", i+1, got, delay)
+		}
+	}
+
+	// пауза 20мс укорачивается не больше чем вдвое
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.delay(2); got < 10*time.Millisecond || got > 20*time.Millisecond {
+			t.Fatalf("unexpected jitter\nGot: %s\nExpected: 10ms..20ms", got)
+		}
+	}
+
+	// без MaxDelay пауза растёт до предела time.Duration, но не переполняется
+	policy = RetryPolicy{BaseDelay: time.Second}.withDefaults()
+	for _, attempt := range []int{40, 100, 2000} {
+		if got := policy.delay(attempt); got != math.MaxInt64 {
+			t.Errorf("results not match for attempt %d\nGot: %d\nExpected: %d", attempt, got, int64(math.MaxInt64))
+		}
+	}
+}
+
+func TestRetryPolicyGivesUp(t *testing.T) {
+	flakyCrc32 := &countingSigner{Signer: Crc32Signer{}, fails: 5}
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	_, err := Retried(flakyCrc32, policy).Sign(context.Background(), "0")
+	if !errors.Is(err, errFlaky) || err.Error() != "after 3 attempts: flaky signer" {
+		t.Errorf("expected flaky error after 3 attempts, got %v", err)
+	}
+	if flakyCrc32.calls != 3 {
+		t.Errorf("expected 3 calls, got %d", flakyCrc32.calls)
+	}
+}
+
+func TestRetryPolicyPermanent(t *testing.T) {
+	calls := 0
+	errBroken := errors.New("broken")
+	err := RetryPolicy{MaxAttempts: 5}.Do(context.Background(), func(context.Context) error {
+		calls++
+		return Permanent(errBroken)
+	})
+
+	if !errors.Is(err, errBroken) || calls != 1 {
+		t.Errorf("permanent error retried: %d calls, %v", calls, err)
+	}
+}
+
+func TestRetryPolicyCancel(t *testing.T) {
+	flakyCrc32 := &countingSigner{Signer: Crc32Signer{}, fails: 5}
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := Retried(flakyCrc32, policy).Sign(ctx, "0")
+	if !errors.Is(err, context.DeadlineExceeded) || flakyCrc32.calls != 1 {
+		t.Errorf("expected deadline during backoff, got %d calls, %v", flakyCrc32.calls, err)
+	}
+}
+
+func TestWithRetryStage(t *testing.T) {
+	failures := map[int]*int32{1: new(int32), 3: new(int32)}
+	atomic.StoreInt32(failures[1], 2)
+	atomic.StoreInt32(failures[3], 1)
+
+	stage := ParallelStage(2, WithRetry(RetryPolicy{BaseDelay: time.Millisecond}, func(_ context.Context, num int) (int, error) {
+		if atomic.AddInt32(failures[num], -1) >= 0 {
+			return 0, errFlaky
+		}
+		return num * 10, nil
+	}))
+
+	results, err := NewPipeline("retried", stage).Collect(context.Background(), 1, 3)
+	if err != nil || len(results) != 2 || results[0]+results[1] != 40 {
+		t.Errorf("unexpected result: %v, %v", results, err)
+	}
+}
+
+func TestSignerPipelineRetry(t *testing.T) {
+	flakyCrc32 := &countingSigner{Signer: Crc32Signer{}, fails: 4}
+	cfg := SignerConfig{
+		Digest:   Md5Signer{},
+		Checksum: flakyCrc32,
+		Retry:    &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond},
+	}
+
+	results, err := SignerPipeline(cfg).Collect(context.Background(), "0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "29568666068035183841425683795340791879727309630931025356555"
+	if len(results) != 1 || results[0] != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, expected)
+	}
+}
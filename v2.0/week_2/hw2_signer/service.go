@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// ErrServiceClosed is returned for requests to a closed SignService.
+var ErrServiceClosed = errors.New("sign service closed")
+
+// signRequest travels through the shared pipeline of SignService. hash holds
+// the data, then the result of every stage; the stages skip failed requests.
+type signRequest struct {
+	ctx   context.Context
+	hash  string
+	err   error
+	reply chan struct{}
+}
+
+// requestStage runs fn over the hash of every request with the request's context,
+// the errors stay with the request instead of stopping the shared pipeline.
+func requestStage(workers int, fn func(ctx context.Context, data string) (string, error)) Stage[*signRequest, *signRequest] {
+	return ParallelStage(workers, func(_ context.Context, req *signRequest) (*signRequest, error) {
+		if req.err == nil {
+			req.err = req.ctx.Err()
+		}
+		if req.err == nil {
+			req.hash, req.err = safeCall(req.ctx, fn, req.hash)
+		}
+		return req, nil
+	})
+}
+
+// SignService signs values of concurrent requests with a single long-lived
+// SingleHash -> MultiHash pipeline.
+type SignService struct {
+	in     chan *signRequest
+	cancel context.CancelFunc
+	done   chan struct{}
+	// senders is the number of values of a batch that are in flight at once
+	senders int
+}
+
+// NewSignService starts the pipeline with the signers, workers, timeouts and metrics of cfg.
+func NewSignService(cfg SignerConfig) *SignService {
+	signers := cfg.signers()
+	single := MeasureItems(cfg.Metrics, "SingleHash", WithTimeout(cfg.ItemTimeout, signers.singleHash))
+	multi := MeasureItems(cfg.Metrics, "MultiHash", WithTimeout(cfg.ItemTimeout, signers.multiHash))
+	p := NewPipeline("SingleHash", InstrumentStage(cfg.Metrics, "SingleHash", cfg.SingleHashWorkers, requestStage(cfg.SingleHashWorkers, single)))
+	p = Then(p, "MultiHash", InstrumentStage(cfg.Metrics, "MultiHash", cfg.MultiHashWorkers, requestStage(cfg.MultiHashWorkers, multi)))
+
+	senders := cfg.SingleHashWorkers
+	if senders <= 0 {
+		senders = DefaultWorkers
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &SignService{
+		in:      make(chan *signRequest),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		senders: senders,
+	}
+
+	go func() {
+		defer close(s.done)
+		p.Run(ctx, s.in, func(req *signRequest) error {
+			close(req.reply)
+			return nil
+		})
+	}()
+
+	return s
+}
+
+// Close stops the pipeline, pending requests fail with ErrServiceClosed.
+func (s *SignService) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Sign returns MultiHash(SingleHash(data)).
+func (s *SignService) Sign(ctx context.Context, data string) (string, error) {
+	req := &signRequest{ctx: ctx, hash: data, reply: make(chan struct{})}
+
+	select {
+	case s.in <- req:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-s.done:
+		return "", ErrServiceClosed
+	}
+
+	select {
+	case <-req.reply:
+		return req.hash, req.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-s.done:
+		return "", ErrServiceClosed
+	}
+}
+
+// SignBatch signs the values concurrently, as many at once as SingleHash has
+// workers, and combines the hashes like CombineResults. The first failed value
+// cancels the rest.
+func (s *SignService) SignBatch(ctx context.Context, data []string) (hashes []string, combined string, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+	)
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range data {
+			if !sendItem(ctx, indexes, i) {
+				return
+			}
+		}
+	}()
+
+	hashes = make([]string, len(data))
+	wg := &sync.WaitGroup{}
+	for n := 0; n < s.senders && n < len(data); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				hash, err := s.Sign(ctx, data[i])
+				if err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("item %d: %w", i, err)
+						cancel()
+					})
+					continue
+				}
+				hashes[i] = hash
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, "", firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	return hashes, combineResults(append([]string(nil), hashes...)), nil
+}
+
+type signResponse struct {
+	Hash string `json:"hash"`
+}
+
+type batchResponse struct {
+	Hashes []string `json:"hashes"`
+	Result string   `json:"result"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// httpError is an error with the status code to answer it with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e httpError) Error() string { return e.err.Error() }
+func (e httpError) Unwrap() error { return e.err }
+
+func errorStatus(err error) int {
+	var httpErr httpError
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr.status
+	case errors.Is(err, ErrServiceClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrItemTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// signValue turns a JSON string or number into the data to sign, like SingleHash
+// accepts strings and ints.
+func signValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	default:
+		return "", httpError{http.StatusBadRequest, fmt.Errorf("%w %T", ErrUnexpectedItem, value)}
+	}
+}
+
+// MaxRequestBody is the size limit of request bodies of SignService.Handler.
+const MaxRequestBody = 1 << 20
+
+func decodeBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		return httpError{status, fmt.Errorf("bad request body: %w", err)}
+	}
+	return nil
+}
+
+// handle adapts an endpoint answering with a JSON body or an error to http.Handler.
+func handle(endpoint func(r *http.Request) (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{"method not allowed"})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBody)
+		body, err := endpoint(r)
+		if err != nil {
+			if r.Context().Err() != nil {
+				// the client is gone, nobody reads the answer
+				return
+			}
+			writeJSON(w, errorStatus(err), errorResponse{err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, body)
+	})
+}
+
+func (s *SignService) handleSign(r *http.Request) (interface{}, error) {
+	var value interface{}
+	if err := decodeBody(r, &value); err != nil {
+		return nil, err
+	}
+
+	data, err := signValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := s.Sign(r.Context(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return signResponse{Hash: hash}, nil
+}
+
+func (s *SignService) handleBatch(r *http.Request) (interface{}, error) {
+	var values []interface{}
+	if err := decodeBody(r, &values); err != nil {
+		return nil, err
+	}
+
+	data := make([]string, len(values))
+	for i, value := range values {
+		var err error
+		data[i], err = signValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+
+	hashes, result, err := s.SignBatch(r.Context(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return batchResponse{Hashes: hashes, Result: result}, nil
+}
+
+// Handler serves POST /sign with a JSON string or number and POST /sign/batch
+// with a JSON array of them. Errors are answered as {"error": "..."}, bodies
+// over MaxRequestBody with 413.
+func (s *SignService) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/sign", handle(s.handleSign))
+	mux.Handle("/sign/batch", handle(s.handleBatch))
+	return mux
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, cfg SignerConfig) *httptest.Server {
+	service := NewSignService(cfg)
+	server := httptest.NewServer(service.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		service.Close()
+	})
+	return server
+}
+
+func post(t *testing.T, url, body string) (int, string) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return resp.StatusCode, string(respBody)
+}
+
+func TestSignServiceHTTP(t *testing.T) {
+	server := newTestService(t, SignerConfig{
+		Digest:   Md5Signer{},
+		Checksum: panickySigner{Signer: Crc32Signer{}, on: "boom"},
+	})
+
+	cases := []struct {
+		path   string
+		body   string
+		status int
+		answer string
+	}{
+		{"/sign", `"0"`, http.StatusOK, `{"hash":"29568666068035183841425683795340791879727309630931025356555"}`},
+		{"/sign", `0`, http.StatusOK, `{"hash":"29568666068035183841425683795340791879727309630931025356555"}`},
+		{"/sign/batch", `[0, 1, 1, 2, 3, 5, 8]`, http.StatusOK, `{"hashes":["29568666068035183841425683795340791879727309630931025356555","4958044192186797981418233587017209679042592862002427381542","4958044192186797981418233587017209679042592862002427381542","27225454331033649287118297354036464389062965355426795162684","1696913515191343735512658979631549563179965036907783101867","3994492081516972096677631278379039212655368881548151736","1173136728138862632818075107442090076184424490584241521304"],"result":"1173136728138862632818075107442090076184424490584241521304_1696913515191343735512658979631549563179965036907783101867_27225454331033649287118297354036464389062965355426795162684_29568666068035183841425683795340791879727309630931025356555_3994492081516972096677631278379039212655368881548151736_4958044192186797981418233587017209679042592862002427381542_4958044192186797981418233587017209679042592862002427381542"}`},
+		{"/sign", `{"data": 1}`, http.StatusBadRequest, `{"error":"unexpected item type map[string]interface {}"}`},
+		{"/sign", `"0`, http.StatusBadRequest, `{"error":"bad request body: unexpected EOF"}`},
+		{"/sign/batch", `[1, true]`, http.StatusBadRequest, `{"error":"item 1: unexpected item type bool"}`},
+		{"/sign/batch", `[1, "boom"]`, http.StatusInternalServerError, `{"error":"item 1: panic on item boom: boom"}`},
+		// сервис переживает панику подписи
+		{"/sign", `"1"`, http.StatusOK, `{"hash":"4958044192186797981418233587017209679042592862002427381542"}`},
+	}
+
+	for _, c := range cases {
+		status, answer := post(t, server.URL+c.path, c.body)
+		if status != c.status || answer != c.answer+"\n" {
+			t.Errorf("answer not match for %s %s\nGot: %d %s\nExpected: %d %s", c.path, c.body, status, answer, c.status, c.answer)
+		}
+	}
+
+	status, answer := post(t, server.URL+"/sign/batch", "["+strings.Repeat(`"0",`, MaxRequestBody/4)+`"0"]`)
+	if status != http.StatusRequestEntityTooLarge || answer != `{"error":"bad request body: http: request body too large"}`+"\n" {
+		t.Errorf("expected 413 for a body over the limit, got %d %s", status, answer)
+	}
+
+	resp, err := http.Get(server.URL + "/sign")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", resp.StatusCode)
+	}
+}
+
+func TestSignServiceRequestCancel(t *testing.T) {
+	service := NewSignService(SignerConfig{Digest: newStuckSigner(t), Checksum: Crc32Signer{}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := service.Sign(ctx, "0")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline of the request, got %v", err)
+	}
+
+	service.Close()
+	if end := time.Since(start); end > 500*time.Millisecond {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, 500*time.Millisecond)
+	}
+
+	_, err = service.Sign(context.Background(), "0")
+	if !errors.Is(err, ErrServiceClosed) {
+		t.Errorf("expected ErrServiceClosed, got %v", err)
+	}
+	if status := errorStatus(err); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for a closed service, got %d", status)
+	}
+}
+
+func TestSignBatchBoundedSenders(t *testing.T) {
+	service := NewSignService(SignerConfig{Digest: newStuckSigner(t), Checksum: Crc32Signer{}, SingleHashWorkers: 2})
+	defer service.Close()
+
+	data := make([]string, 1000)
+	for i := range data {
+		data[i] = strconv.Itoa(i)
+	}
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := service.SignBatch(ctx, data)
+		done <- err
+	}()
+
+	// подписи висят; пулы стадий запускаются вместе с сервисом, а горутина
+	// на каждое значение дала бы больше тысячи
+	time.Sleep(50 * time.Millisecond)
+	if started := runtime.NumGoroutine() - before; started >= len(data)/2 {
+		t.Errorf("too many goroutines for a batch of %d: %d", len(data), started)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancel error, got %v", err)
+	}
+}
@@ -3,108 +3,382 @@ package main
 // сюда писать код
 
 import (
+	"context"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
-func processJob(job job, in, out chan interface{}, wg *sync.WaitGroup) {
+func processJob(job job, in, out chan interface{}, wg *sync.WaitGroup, panics chan<- error, cancel context.CancelFunc) {
 	defer wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			// stop the run before the jobs downstream see out closed and finish
+			// with what they have
+			cancel()
+			panics <- &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		close(out)
+		// a job that quit early won't read in anymore, let the previous one finish
+		drain(in)
+	}()
 	job(in, out)
-	close(out)
 }
 
+// jobContexts are the contexts of ExecutePipeline runs by the input channels of
+// their jobs, so SingleHash, MultiHash and CombineResults stop with the run.
+var jobContexts sync.Map
+
+// jobContext is the context of the run whose job reads in, Background outside
+// of ExecutePipeline.
+func jobContext(in chan interface{}) context.Context {
+	if ctx, ok := jobContexts.Load(in); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+// ExecutePipeline runs jobs connected by channels. A panicking job doesn't take
+// the other goroutines down: the first panic cancels the run, so the signer
+// jobs stop without passing anything on, and is raised again in the caller as
+// a *PanicError once every job has returned.
 func ExecutePipeline(jobs ...job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	in := make(chan interface{}, 1)
+	close(in)
 	out := make(chan interface{}, 1)
+	panics := make(chan error, len(jobs))
 	wg := &sync.WaitGroup{}
 	for _, job := range jobs {
+		jobContexts.Store(in, ctx)
+		defer jobContexts.Delete(in)
+
 		wg.Add(1)
-		go processJob(job, in, out, wg)
+		go processJob(job, in, out, wg, panics, cancel)
 		in = out
 		out = make(chan interface{}, 1)
 	}
 	close(out)
 	wg.Wait()
-}
 
-func computeMd5Safe(data string, mu *sync.Mutex) string {
-	mu.Lock()
-	defer mu.Unlock()
-	return DataSignerMd5(data)
+	select {
+	case err := <-panics:
+		panic(err)
+	default:
+	}
 }
 
-func computeMd5Hash(data string, mu *sync.Mutex) chan string {
-	chRes := make(chan string, 1)
-	go (func(ch chan string) {
-		ch <- computeMd5Safe(data, mu)
-	})(chRes)
-	return chRes
+type signResult struct {
+	hash string
+	err  error
 }
 
-func computeCrc32Hash(data string) chan string {
-	chRes := make(chan string, 1)
-	go (func(ch chan string) {
-		ch <- DataSignerCrc32(data)
+func computeHash(ctx context.Context, signer Signer, data string) chan signResult {
+	chRes := make(chan signResult, 1)
+	go (func(ch chan signResult) {
+		hash, err := safeCall(ctx, signer.Sign, data)
+		ch <- signResult{hash, err}
 	})(chRes)
 	return chRes
 }
 
-func computeSingleHash(data string, out chan interface{}, wg *sync.WaitGroup, mu *sync.Mutex) {
-	md5Ch := computeMd5Hash(data, mu)
-	firstCrc32Ch := computeCrc32Hash(data)
-	secondSrc32Ch := computeCrc32Hash(<-md5Ch)
+// awaitHash waits for a computeHash result. Signers that ignore ctx, like
+// DataSignerMd5, are left running in the background when ctx is done.
+func awaitHash(ctx context.Context, ch chan signResult) signResult {
+	select {
+	case res := <-ch:
+		return res
+	case <-ctx.Done():
+		return signResult{err: ctx.Err()}
+	}
+}
 
-	out <- (<-firstCrc32Ch) + "~" + (<-secondSrc32Ch)
-	wg.Done()
+// pipelineSigners are the signers of SignerConfig with defaults and salt applied.
+type pipelineSigners struct {
+	digest   Signer
+	checksum Signer
 }
 
-func SingleHash(in, out chan interface{}) {
-	mu := &sync.Mutex{}
-	wg := &sync.WaitGroup{}
-	for inputItem := range in {
-		wg.Add(1)
-		go computeSingleHash(strconv.Itoa(inputItem.(int)), out, wg, mu)
+func (s pipelineSigners) singleHash(ctx context.Context, data string) (string, error) {
+	digestCh := computeHash(ctx, s.digest, data)
+	firstChecksumCh := computeHash(ctx, s.checksum, data)
+
+	digest := awaitHash(ctx, digestCh)
+	if digest.err != nil {
+		awaitHash(ctx, firstChecksumCh)
+		return "", digest.err
 	}
-	wg.Wait()
+	secondChecksumCh := computeHash(ctx, s.checksum, digest.hash)
+
+	first, second := awaitHash(ctx, firstChecksumCh), awaitHash(ctx, secondChecksumCh)
+	if first.err != nil {
+		return "", first.err
+	}
+	if second.err != nil {
+		return "", second.err
+	}
+
+	return first.hash + "~" + second.hash, nil
 }
 
-func computeMultiHash(data string, out chan interface{}, wg *sync.WaitGroup) {
-	channels := make([]chan string, 6)
+func (s pipelineSigners) multiHash(ctx context.Context, data string) (string, error) {
+	channels := make([]chan signResult, 6)
 	for i := 0; i < 6; i++ {
-		channels[i] = computeCrc32Hash(strconv.Itoa(i) + data)
+		channels[i] = computeHash(ctx, s.checksum, strconv.Itoa(i)+data)
 	}
 
 	res := strings.Builder{}
+	var firstErr error
 	for i := 0; i < 6; i++ {
-		res.WriteString(<-channels[i])
+		hash := awaitHash(ctx, channels[i])
+		if hash.err != nil && firstErr == nil {
+			firstErr = hash.err
+		}
+		res.WriteString(hash.hash)
 	}
 
-	out <- res.String()
-	wg.Done()
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	return res.String(), nil
 }
 
-func MultiHash(in, out chan interface{}) {
-	wg := &sync.WaitGroup{}
-	for inputItem := range in {
-		wg.Add(1)
-		go computeMultiHash(inputItem.(string), out, wg)
+// SignerConfig configures SignerPipeline, zero fields fall back to defaults.
+type SignerConfig struct {
+	SingleHashWorkers int
+	MultiHashWorkers  int
+	// Ordered keeps the input order through the hash stages, CombineResults
+	// then joins the items in that order instead of sorting them.
+	Ordered bool
+	// Digest takes the md5 role of SingleHash, DataSignerMd5 by default.
+	Digest Signer
+	// Checksum takes the crc32 role of SingleHash and MultiHash, DataSignerCrc32 by default.
+	Checksum Signer
+	// Salt is appended to the data of every Digest and Checksum call.
+	Salt string
+	// DigestLimiter and ChecksumLimiter guard the calls of the signers.
+	// Exclusive signers without a limiter share a Semaphore of 1 with every
+	// other pipeline of the process.
+	DigestLimiter   Limiter
+	ChecksumLimiter Limiter
+	// Retry retries failed Digest and Checksum calls, every attempt waits for the limiter.
+	Retry *RetryPolicy
+	// Cache keeps computed signatures, it may be shared by several pipelines.
+	Cache *SignCache
+	// ItemTimeout fails an item of SingleHash or MultiHash with ErrItemTimeout
+	// when its signatures take longer, retries included.
+	ItemTimeout time.Duration
+	// StageTimeout fails SingleHash or MultiHash with ErrStageTimeout when the
+	// stage hasn't processed all of its input in time.
+	StageTimeout time.Duration
+	// OnPanic tells SingleHash and MultiHash whether an item whose signer
+	// panicked fails the stage or is dropped.
+	OnPanic PanicPolicy
+	// DeadLetters runs SingleHash and MultiHash in skip mode: items that fail
+	// for any reason are recorded there instead of failing the run.
+	DeadLetters *DeadLetters
+	// Clock times the retry pauses when Retry has no clock of its own,
+	// SystemClock by default.
+	Clock Clock
+	// Metrics collects the metrics of the stages of SignerPipeline.
+	Metrics *PipelineMetrics
+	// Tracer records a trace for every input with the spans of the stages and
+	// of the Digest and Checksum calls. Stages used on their own, outside of
+	// SignerPipeline, SignerJobs and PipelineConfig.Build, start a trace per item.
+	Tracer *Tracer
+}
+
+// wrapSigner applies salt, limiter, retries, cache and tracing of the config to s. The cache is
+// the outermost layer, so cache hits don't wait for the limiter.
+func (cfg SignerConfig) wrapSigner(s Signer, limiter Limiter) Signer {
+	if limiter == nil && isExclusive(s) {
+		limiter = exclusiveLimiter(s.Name())
 	}
-	wg.Wait()
+
+	s = Salted(s, cfg.Salt)
+	if limiter != nil {
+		s = Limited(s, limiter)
+	}
+	if cfg.Retry != nil {
+		policy := *cfg.Retry
+		if policy.Clock == nil {
+			policy.Clock = cfg.Clock
+		}
+		s = Retried(s, policy)
+	}
+	if cfg.Cache != nil {
+		s = Cached(s, cfg.Cache)
+	}
+	if cfg.Tracer != nil {
+		s = Traced(s)
+	}
+	return s
 }
 
-func CombineResults(in, out chan interface{}) {
-	accumulator := make([]string, 0)
-	var itemStr string
-	for item := range in {
-		itemStr = item.(string)
-		accumulator = append(accumulator, itemStr)
+func (cfg SignerConfig) signers() pipelineSigners {
+	signers := pipelineSigners{digest: cfg.Digest, checksum: cfg.Checksum}
+	if signers.digest == nil {
+		signers.digest = LegacyMd5Signer()
+	}
+	if signers.checksum == nil {
+		signers.checksum = LegacyCrc32Signer()
 	}
 
+	signers.digest = cfg.wrapSigner(signers.digest, cfg.DigestLimiter)
+	signers.checksum = cfg.wrapSigner(signers.checksum, cfg.ChecksumLimiter)
+	return signers
+}
+
+// NewSingleHashStage computes crc32(data)+"~"+crc32(md5(data)) for every item
+// with the signers and workers of cfg.
+func NewSingleHashStage(cfg SignerConfig) Stage[string, string] {
+	if cfg.Tracer != nil {
+		return untraced(cfg.tracedSingleHashStage())
+	}
+	return itemStage(cfg, "SingleHash", cfg.SingleHashWorkers, cfg.singleHashItems())
+}
+
+// NewMultiHashStage concatenates crc32(th+data) for th=0..5 for every item
+// with the signers and workers of cfg.
+func NewMultiHashStage(cfg SignerConfig) Stage[string, string] {
+	if cfg.Tracer != nil {
+		return untraced(cfg.tracedMultiHashStage())
+	}
+	return itemStage(cfg, "MultiHash", cfg.MultiHashWorkers, cfg.multiHashItems())
+}
+
+func (cfg SignerConfig) singleHashItems() func(ctx context.Context, data string) (string, error) {
+	fn := WithTimeout(cfg.ItemTimeout, OnPanic(cfg.OnPanic, cfg.signers().singleHash))
+	return MeasureItems(cfg.Metrics, "SingleHash", SkipFailed(cfg.DeadLetters, "SingleHash", fn))
+}
+
+func (cfg SignerConfig) multiHashItems() func(ctx context.Context, data string) (string, error) {
+	fn := WithTimeout(cfg.ItemTimeout, OnPanic(cfg.OnPanic, cfg.signers().multiHash))
+	return MeasureItems(cfg.Metrics, "MultiHash", SkipFailed(cfg.DeadLetters, "MultiHash", fn))
+}
+
+// tracedSingleHashStage is NewSingleHashStage on traced items, see tracedItem.
+func (cfg SignerConfig) tracedSingleHashStage() Stage[tracedItem, tracedItem] {
+	return itemStage(cfg, "SingleHash", cfg.SingleHashWorkers, TraceItems(cfg.Tracer, "SingleHash", cfg.singleHashItems()))
+}
+
+// tracedMultiHashStage is NewMultiHashStage on traced items, see tracedItem.
+func (cfg SignerConfig) tracedMultiHashStage() Stage[tracedItem, tracedItem] {
+	return itemStage(cfg, "MultiHash", cfg.MultiHashWorkers, TraceItems(cfg.Tracer, "MultiHash", cfg.multiHashItems()))
+}
+
+// itemStage runs fn over the items with workers, the order, stage timeout and
+// metrics of cfg.
+func itemStage[T any](cfg SignerConfig, name string, workers int, fn func(ctx context.Context, item T) (T, error)) Stage[T, T] {
+	stage := parallelStage(workers, cfg.Ordered, fn)
+	return InstrumentStage(cfg.Metrics, name, workers, StageTimeout(cfg.StageTimeout, stage))
+}
+
+func SingleHashStage(ctx context.Context, in <-chan string, out chan<- string) error {
+	return NewSingleHashStage(SignerConfig{})(ctx, in, out)
+}
+
+func MultiHashStage(ctx context.Context, in <-chan string, out chan<- string) error {
+	return NewMultiHashStage(SignerConfig{})(ctx, in, out)
+}
+
+// NewCombineResultsStage joins all items with "_" into a single result: sorted,
+// or in the order they arrive when inputOrder is set.
+func NewCombineResultsStage(inputOrder bool) Stage[string, string] {
+	return func(ctx context.Context, in <-chan string, out chan<- string) error {
+		accumulator := make([]string, 0)
+		for {
+			item, ok := receiveItem(ctx, in)
+			if !ok {
+				break
+			}
+			accumulator = append(accumulator, item)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if inputOrder {
+			sendItem(ctx, out, strings.Join(accumulator, "_"))
+		} else {
+			sendItem(ctx, out, combineResults(accumulator))
+		}
+		return nil
+	}
+}
+
+func CombineResultsStage(ctx context.Context, in <-chan string, out chan<- string) error {
+	return NewCombineResultsStage(false)(ctx, in, out)
+}
+
+// SignerPipeline is the SingleHash -> MultiHash -> CombineResults chain on typed stages.
+// With a Tracer the stages pass traced items, so every input keeps one trace.
+func SignerPipeline(cfg SignerConfig) Pipeline[string, string] {
+	if cfg.Tracer != nil {
+		p := NewPipeline("SingleHash", startTraces(cfg.tracedSingleHashStage()))
+		p = Then(p, "MultiHash", cfg.tracedMultiHashStage())
+		return Then(p, "CombineResults", dropTraces(cfg.tracedCombineResultsStage()))
+	}
+
+	p := NewPipeline("SingleHash", NewSingleHashStage(cfg))
+	p = Then(p, "MultiHash", NewMultiHashStage(cfg))
+	return Then(p, "CombineResults", cfg.combineResultsStage())
+}
+
+func (cfg SignerConfig) combineResultsStage() Stage[string, string] {
+	if cfg.Tracer != nil {
+		return untraced(cfg.tracedCombineResultsStage())
+	}
+	return InstrumentStage(cfg.Metrics, "CombineResults", 1, NewCombineResultsStage(cfg.Ordered))
+}
+
+func (cfg SignerConfig) tracedCombineResultsStage() Stage[tracedItem, tracedItem] {
+	stage := TraceCollect(cfg.Tracer, "CombineResults", NewCombineResultsStage(cfg.Ordered))
+	return InstrumentStage(cfg.Metrics, "CombineResults", 1, stage)
+}
+
+// SignerJobs are the stages of SignerPipeline(cfg) as jobs of ExecutePipelineErr,
+// SingleHash takes int or string items. With DeadLetters the items of other
+// types are dead letters too, ExecutePipelineReport returns the counts of the run.
+func SignerJobs(cfg SignerConfig) []jobErr {
+	if cfg.Tracer != nil {
+		return []jobErr{
+			AdaptStageSkip(cfg.DeadLetters, "SingleHash", startTraces(cfg.tracedSingleHashStage()), numberOrString),
+			AdaptStageSkip(cfg.DeadLetters, "MultiHash", cfg.tracedMultiHashStage(), traceableItem),
+			AdaptStageSkip(cfg.DeadLetters, "CombineResults", dropTraces(cfg.tracedCombineResultsStage()), traceableItem),
+		}
+	}
+
+	return []jobErr{
+		AdaptStageSkip(cfg.DeadLetters, "SingleHash", NewSingleHashStage(cfg), numberOrString),
+		AdaptStageSkip(cfg.DeadLetters, "MultiHash", NewMultiHashStage(cfg), stringItem),
+		AdaptStageSkip(cfg.DeadLetters, "CombineResults", cfg.combineResultsStage(), stringItem),
+	}
+}
+
+func SingleHash(in, out chan interface{}) {
+	SingleHashContext(jobContext(in), in, out)
+}
+
+func MultiHash(in, out chan interface{}) {
+	MultiHashContext(jobContext(in), in, out)
+}
+
+func CombineResults(in, out chan interface{}) {
+	CombineResultsContext(jobContext(in), in, out)
+}
+
+func combineResults(accumulator []string) string {
 	sort.Slice(accumulator, func(i, j int) bool {
 		return accumulator[i] < accumulator[j]
 	})
 
-	out <- strings.Join(accumulator, "_")
+	return strings.Join(accumulator, "_")
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/crc32"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Signer computes the signature of one value. Implementations have to be safe
+// for concurrent use.
+type Signer interface {
+	// Name identifies the algorithm, e.g. in errors and cache keys.
+	Name() string
+	Sign(ctx context.Context, data string) (string, error)
+}
+
+// Md5Signer is md5 as hex, the format of DataSignerMd5.
+type Md5Signer struct{}
+
+func (Md5Signer) Name() string { return "md5" }
+
+func (Md5Signer) Sign(_ context.Context, data string) (string, error) {
+	sum := md5.Sum([]byte(data))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Crc32Signer is IEEE crc32 as a decimal number, the format of DataSignerCrc32.
+type Crc32Signer struct{}
+
+func (Crc32Signer) Name() string { return "crc32" }
+
+func (Crc32Signer) Sign(_ context.Context, data string) (string, error) {
+	return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(data))), 10), nil
+}
+
+// Sha256Signer is sha256 as hex.
+type Sha256Signer struct{}
+
+func (Sha256Signer) Name() string { return "sha256" }
+
+func (Sha256Signer) Sign(_ context.Context, data string) (string, error) {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HmacSha256Signer is HMAC-SHA256 with Key as hex.
+type HmacSha256Signer struct {
+	Key []byte
+}
+
+func (HmacSha256Signer) Name() string { return "hmac-sha256" }
+
+// cacheKey tells keys apart by their hash, the key itself never gets into the cache.
+func (s HmacSha256Signer) cacheKey() string {
+	sum := sha256.Sum256(s.Key)
+	return "hmac-sha256:" + hex.EncodeToString(sum[:8])
+}
+
+func (s HmacSha256Signer) Sign(_ context.Context, data string) (string, error) {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// XXHashSigner is 64-bit xxhash as a decimal number.
+type XXHashSigner struct{}
+
+func (XXHashSigner) Name() string { return "xxhash" }
+
+func (XXHashSigner) Sign(_ context.Context, data string) (string, error) {
+	return strconv.FormatUint(xxhash.Sum64String(data), 10), nil
+}
+
+// legacySigner calls one of the DataSigner* package variables. The variable is
+// read on every call, so tests replacing DataSignerMd5 or DataSignerCrc32 keep working.
+type legacySigner struct {
+	name string
+	fn   func() func(data string) string
+}
+
+func (s legacySigner) Name() string { return s.name }
+
+// cacheKey includes the global DataSignerSalt the DataSigner* functions append themselves.
+func (s legacySigner) cacheKey() string { return s.name + "+" + DataSignerSalt }
+
+func (s legacySigner) Sign(_ context.Context, data string) (string, error) {
+	return s.fn()(data), nil
+}
+
+type legacyExclusiveSigner struct {
+	legacySigner
+}
+
+func (legacyExclusiveSigner) Exclusive() bool { return true }
+
+// LegacyMd5Signer is DataSignerMd5 with its global DataSignerSalt. It is
+// Exclusive, concurrent calls overheat it.
+func LegacyMd5Signer() Signer {
+	return legacyExclusiveSigner{legacySigner{name: "legacy-md5", fn: func() func(string) string { return DataSignerMd5 }}}
+}
+
+// LegacyCrc32Signer is DataSignerCrc32 with its global DataSignerSalt.
+func LegacyCrc32Signer() Signer {
+	return legacySigner{name: "legacy-crc32", fn: func() func(string) string { return DataSignerCrc32 }}
+}
+
+type saltedSigner struct {
+	Signer
+	salt string
+}
+
+func (s saltedSigner) cacheKey() string {
+	return signerCacheKey(s.Signer) + "+" + s.salt
+}
+
+func (s saltedSigner) Sign(ctx context.Context, data string) (string, error) {
+	return s.Signer.Sign(ctx, data+s.salt)
+}
+
+// Salted appends salt to the data before signing it with s.
+func Salted(s Signer, salt string) Signer {
+	if salt == "" {
+		return s
+	}
+	return saltedSigner{Signer: s, salt: salt}
+}
+
+// SignerByName returns the algorithm with the given Name, key is used by hmac-sha256 only.
+func SignerByName(name string, key []byte) (Signer, bool) {
+	switch name {
+	case "md5":
+		return Md5Signer{}, true
+	case "crc32":
+		return Crc32Signer{}, true
+	case "sha256":
+		return Sha256Signer{}, true
+	case "hmac-sha256":
+		return HmacSha256Signer{Key: key}, true
+	case "xxhash":
+		return XXHashSigner{}, true
+	case "legacy-md5":
+		return LegacyMd5Signer(), true
+	case "legacy-crc32":
+		return LegacyCrc32Signer(), true
+	default:
+		return nil, false
+	}
+}
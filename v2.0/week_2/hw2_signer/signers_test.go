@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSigners(t *testing.T) {
+	cases := []struct {
+		signer   Signer
+		expected string
+	}{
+		{Md5Signer{}, "cfcd208495d565ef66e7dff9f98764da"},
+		{Crc32Signer{}, "4108050209"},
+		{Sha256Signer{}, "5feceb66ffc86f38d952786c6d696c79c2dbc239dd4e91b46729d73a27fb57e9"},
+		{HmacSha256Signer{Key: []byte("key")}, "089c386a9149b5cce5972bfe0f05c8d6e92de22e902457b3a23a69a79f85fa97"},
+		{XXHashSigner{}, "7148434200721666028"},
+		{Salted(Crc32Signer{}, "salt"), "3384569968"},
+	}
+
+	for _, c := range cases {
+		hash, err := c.signer.Sign(context.Background(), "0")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.signer.Name(), err)
+		}
+		if hash != c.expected {
+			t.Errorf("%s: results not match\nGot: %v\nExpected: %v", c.signer.Name(), hash, c.expected)
+		}
+	}
+}
+
+// signManually считает тот же результат, что и конвейер на одном значении, без конвейера.
+func signManually(t *testing.T, digest, checksum Signer, data string) string {
+	ctx := context.Background()
+	sign := func(s Signer, data string) string {
+		hash, err := s.Sign(ctx, data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return hash
+	}
+
+	single := sign(checksum, data) + "~" + sign(checksum, sign(digest, data))
+	multi := ""
+	for th := 0; th < 6; th++ {
+		multi += sign(checksum, strconv.Itoa(th)+single)
+	}
+	return multi
+}
+
+func TestSignerPipelinesSideBySide(t *testing.T) {
+	configs := []SignerConfig{
+		{Digest: Md5Signer{}, Checksum: Crc32Signer{}},
+		{Digest: Sha256Signer{}, Checksum: XXHashSigner{}, Salt: "pepper"},
+		{Digest: HmacSha256Signer{Key: []byte("key")}, Checksum: Crc32Signer{}, Salt: "salt"},
+	}
+	expected := []string{
+		// значение из задания для 0 - md5 и crc32 совпадают с DataSigner*
+		"29568666068035183841425683795340791879727309630931025356555",
+		signManually(t, Salted(Sha256Signer{}, "pepper"), Salted(XXHashSigner{}, "pepper"), "0"),
+		signManually(t, Salted(HmacSha256Signer{Key: []byte("key")}, "salt"), Salted(Crc32Signer{}, "salt"), "0"),
+	}
+
+	results := make([][]string, len(configs))
+	errs := make([]error, len(configs))
+	wg := &sync.WaitGroup{}
+	for i, cfg := range configs {
+		wg.Add(1)
+		go func(i int, cfg SignerConfig) {
+			defer wg.Done()
+			results[i], errs[i] = SignerPipeline(cfg).Collect(context.Background(), "0")
+		}(i, cfg)
+	}
+	wg.Wait()
+
+	for i := range configs {
+		if errs[i] != nil {
+			t.Errorf("pipeline %d: unexpected error: %v", i, errs[i])
+			continue
+		}
+		if len(results[i]) != 1 || results[i][0] != expected[i] {
+			t.Errorf("pipeline %d: results not match\nGot: %v\nExpected: %v", i, results[i], expected[i])
+		}
+	}
+	if expected[1] == expected[2] {
+		t.Errorf("different algorithms should give different results")
+	}
+}
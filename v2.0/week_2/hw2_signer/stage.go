@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Stage is a typed pipeline step. It reads in until it is closed or ctx is done
+// and writes results to out; closing out is up to the pipeline.
+type Stage[In, Out any] func(ctx context.Context, in <-chan In, out chan<- Out) error
+
+// Pipeline is a chain of named stages turning In values into Out values.
+// Build it with NewPipeline and Then, the zero value is not usable.
+type Pipeline[In, Out any] struct {
+	start func(ctx context.Context, g *stageGroup, in <-chan In) <-chan Out
+}
+
+// stageGroup tracks the goroutines of one run, the first failure cancels the rest.
+type stageGroup struct {
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+	cancel context.CancelFunc
+}
+
+func (g *stageGroup) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// receiveItem is receive for typed channels.
+func receiveItem[T any](ctx context.Context, in <-chan T) (T, bool) {
+	var zero T
+	// select picks randomly among ready cases, cancellation has to win
+	if ctx.Err() != nil {
+		return zero, false
+	}
+
+	select {
+	case <-ctx.Done():
+		return zero, false
+	case item, ok := <-in:
+		return item, ok
+	}
+}
+
+// sendItem is send for typed channels.
+func sendItem[T any](ctx context.Context, out chan<- T, item T) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case out <- item:
+		return true
+	}
+}
+
+// drainItems reads in until it is closed or ctx is done.
+func drainItems[T any](ctx context.Context, in <-chan T) {
+	for {
+		if _, ok := receiveItem(ctx, in); !ok {
+			return
+		}
+	}
+}
+
+// pumpInput runs stage on the items of in passed through fn by a goroutine of
+// its own. Items fn fails with ErrSkipItem are dropped, any other error of fn
+// cancels the stage and is returned instead of the stage's own. The rest of the
+// input is drained once the stage returns, so the pump never blocks.
+func pumpInput[In, Mid, Out any](ctx context.Context, in <-chan In, out chan<- Out, fn func(ctx context.Context, item In) (Mid, error), stage Stage[Mid, Out]) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var pumpErr error
+	pumped := make(chan Mid)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(pumped)
+		for {
+			item, ok := receiveItem(ctx, in)
+			if !ok {
+				return
+			}
+
+			result, err := fn(ctx, item)
+			if errors.Is(err, ErrSkipItem) {
+				continue
+			}
+			if err != nil {
+				pumpErr = err
+				cancel()
+				return
+			}
+
+			if !sendItem(ctx, pumped, result) {
+				return
+			}
+		}
+	}()
+
+	err := runStage(ctx, stage, pumped, out)
+	cancel()
+	drainItems(context.Background(), pumped)
+	<-done
+
+	if pumpErr != nil {
+		return pumpErr
+	}
+	return err
+}
+
+// pumpOutput runs stage and hands its results to forward in a goroutine of its
+// own. It returns once every result has been forwarded.
+func pumpOutput[In, Out any](ctx context.Context, in <-chan In, stage Stage[In, Out], forward func(item Out)) error {
+	results := make(chan Out)
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for item := range results {
+			forward(item)
+		}
+	}()
+
+	err := runStage(ctx, stage, in, results)
+	close(results)
+	<-forwarded
+	return err
+}
+
+func runStage[In, Out any](ctx context.Context, stage Stage[In, Out], in <-chan In, out chan<- Out) (err error) {
+	defer catchPanic(nil, &err)
+	return stage(ctx, in, out)
+}
+
+func startStage[In, Out any](ctx context.Context, g *stageGroup, name string, stage Stage[In, Out], in <-chan In, buffer int) <-chan Out {
+	out := make(chan Out, buffer)
+
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			close(out)
+			drainItems(ctx, in)
+			g.wg.Done()
+		}()
+
+		err := runStage(ctx, stage, in, out)
+		if err != nil {
+			g.fail(fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+
+	return out
+}
+
+// NewPipeline starts a pipeline with a single stage.
+func NewPipeline[In, Out any](name string, stage Stage[In, Out]) Pipeline[In, Out] {
+	return NewBufferedPipeline(name, MaxInputDataLen, stage)
+}
+
+// NewBufferedPipeline is NewPipeline with buffer items of room in the output channel of stage.
+func NewBufferedPipeline[In, Out any](name string, buffer int, stage Stage[In, Out]) Pipeline[In, Out] {
+	return Pipeline[In, Out]{
+		start: func(ctx context.Context, g *stageGroup, in <-chan In) <-chan Out {
+			return startStage(ctx, g, name, stage, in, buffer)
+		},
+	}
+}
+
+// Then appends stage to p, the stage input type has to match the output of p.
+func Then[In, Mid, Out any](p Pipeline[In, Mid], name string, stage Stage[Mid, Out]) Pipeline[In, Out] {
+	return ThenBuffered(p, name, MaxInputDataLen, stage)
+}
+
+// ThenBuffered is Then with buffer items of room in the output channel of stage.
+func ThenBuffered[In, Mid, Out any](p Pipeline[In, Mid], name string, buffer int, stage Stage[Mid, Out]) Pipeline[In, Out] {
+	return Pipeline[In, Out]{
+		start: func(ctx context.Context, g *stageGroup, in <-chan In) <-chan Out {
+			return startStage(ctx, g, name, stage, p.start(ctx, g, in), buffer)
+		},
+	}
+}
+
+// Run feeds in through the stages and hands every result to sink. The caller
+// closes in when there is no more input. The first error of a stage or of sink
+// cancels the run and is returned after all stages have exited.
+func (p Pipeline[In, Out]) Run(ctx context.Context, in <-chan In, sink func(Out) error) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g := &stageGroup{cancel: cancel}
+	failed := false
+	for item := range p.start(runCtx, g, in) {
+		if failed {
+			continue
+		}
+		err := sink(item)
+		if err != nil {
+			failed = true
+			g.fail(err)
+		}
+	}
+
+	g.wg.Wait()
+
+	if g.err != nil {
+		return g.err
+	}
+
+	return ctx.Err()
+}
+
+// Collect runs the pipeline over inputs and returns all results.
+func (p Pipeline[In, Out]) Collect(ctx context.Context, inputs ...In) ([]Out, error) {
+	in := make(chan In, len(inputs))
+	for _, input := range inputs {
+		in <- input
+	}
+	close(in)
+
+	results := make([]Out, 0, len(inputs))
+	err := p.Run(ctx, in, func(result Out) error {
+		results = append(results, result)
+		return nil
+	})
+
+	return results, err
+}
+
+// mapInput runs stage on the items of in turned into Mid by convert.
+func mapInput[In, Mid, Out any](stage Stage[Mid, Out], convert func(In) Mid) Stage[In, Out] {
+	convertItem := func(_ context.Context, item In) (Mid, error) {
+		return convert(item), nil
+	}
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		return pumpInput(ctx, in, out, convertItem, stage)
+	}
+}
+
+// mapOutput runs stage and turns its results into Out by convert.
+func mapOutput[In, Mid, Out any](stage Stage[In, Mid], convert func(Mid) Out) Stage[In, Out] {
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		return pumpOutput(ctx, in, stage, func(item Mid) {
+			sendItem(ctx, out, convert(item))
+		})
+	}
+}
+
+// AdaptStage turns a typed stage into a jobErr for ExecutePipelineErr. Items
+// convert can't turn into In fail the job with ErrUnexpectedItem.
+func AdaptStage[In, Out any](name string, stage Stage[In, Out], convert func(item interface{}) (In, bool)) jobErr {
+	return AdaptStageSkip(nil, name, stage, convert)
+}
+
+// AdaptStageSkip is AdaptStage in skip mode: items convert can't turn into In
+// go to the dead letters of d and the job goes on. Nil d is AdaptStage.
+func AdaptStageSkip[In, Out any](d *DeadLetters, name string, stage Stage[In, Out], convert func(item interface{}) (In, bool)) jobErr {
+	convertItem := func(ctx context.Context, item interface{}) (In, error) {
+		typedItem, ok := convert(item)
+		if ok {
+			return typedItem, nil
+		}
+
+		err := unexpectedItem(name, item)
+		if d != nil {
+			if err = d.put(ctx, name, item, err); err == nil {
+				return typedItem, ErrSkipItem
+			}
+		}
+		return typedItem, err
+	}
+
+	return func(ctx context.Context, in, out chan interface{}) error {
+		return pumpInput(ctx, in, out, convertItem, func(ctx context.Context, typedIn <-chan In, out chan<- interface{}) error {
+			return pumpOutput(ctx, typedIn, stage, func(item Out) {
+				sendItem[interface{}](ctx, out, item)
+			})
+		})
+	}
+}
+
+func intItem(item interface{}) (string, bool) {
+	num, ok := item.(int)
+	return strconv.Itoa(num), ok
+}
+
+func stringItem(item interface{}) (string, bool) {
+	str, ok := item.(string)
+	return str, ok
+}
+
+func numberOrString(item interface{}) (string, bool) {
+	if str, ok := stringItem(item); ok {
+		return str, true
+	}
+	return intItem(item)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func itoaStage(ctx context.Context, in <-chan int, out chan<- string) error {
+	for {
+		num, ok := receiveItem(ctx, in)
+		if !ok {
+			return nil
+		}
+		sendItem(ctx, out, strconv.Itoa(num))
+	}
+}
+
+func lenStage(ctx context.Context, in <-chan string, out chan<- int) error {
+	for {
+		str, ok := receiveItem(ctx, in)
+		if !ok {
+			return nil
+		}
+		sendItem(ctx, out, len(str))
+	}
+}
+
+func TestPipelineCollect(t *testing.T) {
+	p := Then(NewPipeline("itoa", itoaStage), "len", lenStage)
+
+	results, err := p.Collect(context.Background(), 1, 22, 333)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 3 || results[0] != 1 || results[1] != 2 || results[2] != 3 {
+		t.Errorf("results not match\nGot: %v\nExpected: [1 2 3]", results)
+	}
+}
+
+func TestPipelineSinkError(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	errSink := errors.New("sink failed")
+	in := make(chan int)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		// вход не закрывается, остановить конвейер может только ошибка
+		for i := 0; ; i++ {
+			select {
+			case in <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	p := NewPipeline("itoa", itoaStage)
+	var got int
+	err := p.Run(context.Background(), in, func(string) error {
+		got++
+		if got == 5 {
+			return errSink
+		}
+		return nil
+	})
+
+	if !errors.Is(err, errSink) {
+		t.Errorf("expected sink error, got %v", err)
+	}
+	if got != 5 {
+		t.Errorf("sink called after error: %d", got)
+	}
+
+	waitGoroutines(t, before+1)
+}
+
+func TestPipelineStageError(t *testing.T) {
+	errStage := errors.New("stage failed")
+	p := Then(NewPipeline("itoa", itoaStage), "broken", func(ctx context.Context, in <-chan string, out chan<- string) error {
+		return errStage
+	})
+
+	_, err := p.Collect(context.Background(), 1, 2, 3)
+	if !errors.Is(err, errStage) || err.Error() != "broken: stage failed" {
+		t.Errorf("expected named stage error, got %v", err)
+	}
+}
+
+func TestSignerPipeline(t *testing.T) {
+	results, err := SignerPipeline(SignerConfig{}).Collect(context.Background(), "0", "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+	if len(results) != 1 || results[0] != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, expected)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrItemTimeout is returned for an item that wasn't processed within its deadline.
+	ErrItemTimeout = errors.New("item timed out")
+	// ErrStageTimeout is returned by a stage that didn't finish within its deadline.
+	ErrStageTimeout = errors.New("stage timed out")
+)
+
+// timedOut tells whether ctx derived from parent ended by its own deadline.
+func timedOut(parent, ctx context.Context) bool {
+	return parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// WithTimeout gives every call of fn d to complete, otherwise the item fails
+// with ErrItemTimeout. fn runs in its own goroutine, so a call that ignores ctx
+// is abandoned instead of blocking the worker. Zero d disables the timeout.
+func WithTimeout[In, Out any](d time.Duration, fn func(ctx context.Context, item In) (Out, error)) func(ctx context.Context, item In) (Out, error) {
+	if d <= 0 {
+		return fn
+	}
+
+	return func(parent context.Context, item In) (Out, error) {
+		ctx, cancel := context.WithTimeout(parent, d)
+		defer cancel()
+
+		res := make(chan orderedResult[Out], 1)
+		go func() {
+			result, err := safeCall(ctx, fn, item)
+			res <- orderedResult[Out]{result, err}
+		}()
+
+		var zero Out
+		select {
+		case r := <-res:
+			if r.err != nil && timedOut(parent, ctx) {
+				return zero, fmt.Errorf("item %v: %w after %s", item, ErrItemTimeout, d)
+			}
+			return r.result, r.err
+		case <-ctx.Done():
+			if timedOut(parent, ctx) {
+				return zero, fmt.Errorf("item %v: %w after %s", item, ErrItemTimeout, d)
+			}
+			return zero, parent.Err()
+		}
+	}
+}
+
+// StageTimeout cancels stage after d and fails it with ErrStageTimeout. The
+// stage still has to return on ctx, the signer stages do. Zero d disables the timeout.
+func StageTimeout[In, Out any](d time.Duration, stage Stage[In, Out]) Stage[In, Out] {
+	if d <= 0 {
+		return stage
+	}
+
+	return func(parent context.Context, in <-chan In, out chan<- Out) error {
+		ctx, cancel := context.WithTimeout(parent, d)
+		defer cancel()
+
+		err := stage(ctx, in, out)
+		if timedOut(parent, ctx) {
+			return fmt.Errorf("%w after %s", ErrStageTimeout, d)
+		}
+		return err
+	}
+}
+
+// JobTimeout is StageTimeout for the jobs of ExecutePipelineErr.
+func JobTimeout(d time.Duration, j jobErr) jobErr {
+	if d <= 0 {
+		return j
+	}
+
+	return func(parent context.Context, in, out chan interface{}) error {
+		ctx, cancel := context.WithTimeout(parent, d)
+		defer cancel()
+
+		err := j(ctx, in, out)
+		if timedOut(parent, ctx) {
+			return fmt.Errorf("%w after %s", ErrStageTimeout, d)
+		}
+		return err
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// stuckSigner висит, пока не закроют release, и не смотрит на ctx, как зависший DataSignerMd5.
+type stuckSigner struct {
+	release chan struct{}
+}
+
+func newStuckSigner(t *testing.T) stuckSigner {
+	s := stuckSigner{release: make(chan struct{})}
+	t.Cleanup(func() { close(s.release) })
+	return s
+}
+
+func (stuckSigner) Name() string { return "stuck" }
+
+func (s stuckSigner) Sign(_ context.Context, data string) (string, error) {
+	<-s.release
+	return data, nil
+}
+
+func TestWithTimeout(t *testing.T) {
+	stuck := newStuckSigner(t)
+	sign := WithTimeout(20*time.Millisecond, stuck.Sign)
+
+	start := time.Now()
+	_, err := sign(context.Background(), "7")
+	end := time.Since(start)
+
+	if !errors.Is(err, ErrItemTimeout) || err.Error() != "item 7: item timed out after 20ms" {
+		t.Errorf("expected item timeout, got %v", err)
+	}
+	if end > 200*time.Millisecond {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, 200*time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sign(ctx, "7"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation of the caller, got %v", err)
+	}
+}
+
+func TestSignerPipelineItemTimeout(t *testing.T) {
+	cfg := SignerConfig{
+		Digest:      newStuckSigner(t),
+		Checksum:    Crc32Signer{},
+		ItemTimeout: 50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := SignerPipeline(cfg).Collect(context.Background(), "0", "1")
+	end := time.Since(start)
+
+	if !errors.Is(err, ErrItemTimeout) {
+		t.Errorf("expected item timeout, got %v", err)
+	}
+	if end > 500*time.Millisecond {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, 500*time.Millisecond)
+	}
+}
+
+func TestSignerPipelineStageTimeout(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// настоящий DataSignerCrc32 считает секунду, ни одна из стадий не успевает
+	cfg := SignerConfig{
+		Digest:       Md5Signer{},
+		StageTimeout: 100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := SignerPipeline(cfg).Collect(context.Background(), "0")
+	end := time.Since(start)
+
+	if !errors.Is(err, ErrStageTimeout) {
+		t.Errorf("expected stage timeout, got %v", err)
+	}
+	if end > 500*time.Millisecond {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, 500*time.Millisecond)
+	}
+
+	// брошенные вызовы DataSignerCrc32 досчитывают свою секунду
+	waitGoroutines(t, before)
+}
+
+func TestExecutePipelineErrJobTimeout(t *testing.T) {
+	jobs := []jobErr{
+		func(ctx context.Context, in, out chan interface{}) error {
+			send(ctx, out, 0)
+			return nil
+		},
+		JobTimeout(100*time.Millisecond, SingleHashErr),
+		MultiHashErr,
+		CombineResultsErr,
+	}
+
+	start := time.Now()
+	err := ExecutePipelineErr(context.Background(), jobs...)
+	end := time.Since(start)
+
+	if !errors.Is(err, ErrStageTimeout) || err.Error() != "stage 1: stage timed out after 100ms" {
+		t.Errorf("expected stage 1 timeout, got %v", err)
+	}
+	if end > 500*time.Millisecond {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, 500*time.Millisecond)
+	}
+}
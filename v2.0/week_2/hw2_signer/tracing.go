@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Span is a timed step of one input value through the pipeline.
+type Span struct {
+	// TraceID identifies the input value, every input gets its own trace.
+	TraceID  int
+	ID       int
+	ParentID int
+	Name     string
+	Start    time.Time
+	End      time.Time
+	Attrs    map[string]string
+	Err      error
+
+	tracer *Tracer
+}
+
+type traceInfo struct {
+	id    int
+	input string
+}
+
+// tracedItem is an item passed between traced stages with the trace of the
+// input it comes from, zero for an item that has no trace yet.
+type tracedItem struct {
+	trace int
+	data  string
+}
+
+// Tracer records the spans of the inputs of instrumented stages. The traced
+// stages pass tracedItem values to each other, so an output of one stage
+// continues the trace of its input in the next one.
+type Tracer struct {
+	clock Clock
+
+	mu     sync.Mutex
+	start  time.Time
+	spans  []*Span
+	traces []traceInfo
+}
+
+// NewTracer measures time with clock, SystemClock if nil.
+func NewTracer(clock Clock) *Tracer {
+	clock = clockOrSystem(clock)
+	return &Tracer{clock: clock, start: clock.Now()}
+}
+
+type spanKey struct{}
+
+func (t *Tracer) newSpan(traceID, parentID int, name string, attrs map[string]string) *Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &Span{
+		TraceID:  traceID,
+		ID:       len(t.spans) + 1,
+		ParentID: parentID,
+		Name:     name,
+		Start:    t.clock.Now(),
+		Attrs:    attrs,
+		tracer:   t,
+	}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+// finish ends the span, err is recorded as its outcome.
+func (s *Span) finish(err error) {
+	t := s.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s.End = t.clock.Now()
+	s.Err = err
+}
+
+// startSpan starts a child of the span in ctx. Without a span in ctx nothing
+// is traced and finish does nothing.
+func startSpan(ctx context.Context, name string, attrs map[string]string) (context.Context, func(err error)) {
+	parent, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok {
+		return ctx, func(error) {}
+	}
+
+	span := parent.tracer.newSpan(parent.TraceID, parent.ID, name, attrs)
+	return context.WithValue(ctx, spanKey{}, span), span.finish
+}
+
+// traceOf is the trace of item, an item without one starts a new trace.
+func (t *Tracer) traceOf(item tracedItem) int {
+	if item.trace != 0 {
+		return item.trace
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := len(t.traces) + 1
+	t.traces = append(t.traces, traceInfo{id: id, input: item.data})
+	return id
+}
+
+// TraceItems records a span of stage for every item of fn, the signers called
+// by fn add their spans as children. The result keeps the trace of the item.
+func TraceItems(t *Tracer, stage string, fn func(ctx context.Context, data string) (string, error)) func(ctx context.Context, item tracedItem) (tracedItem, error) {
+	if t == nil {
+		return func(ctx context.Context, item tracedItem) (tracedItem, error) {
+			result, err := fn(ctx, item.data)
+			return tracedItem{trace: item.trace, data: result}, err
+		}
+	}
+
+	return func(ctx context.Context, item tracedItem) (tracedItem, error) {
+		span := t.newSpan(t.traceOf(item), 0, stage, map[string]string{"data": item.data})
+		result, err := fn(context.WithValue(ctx, spanKey{}, span), item.data)
+		span.finish(err)
+		return tracedItem{trace: span.TraceID, data: result}, err
+	}
+}
+
+// TraceCollect records a span of stage for every item of a stage that collects
+// its whole input, like CombineResults. The spans end when the stage returns,
+// its results belong to no trace.
+func TraceCollect(t *Tracer, stage string, collect Stage[string, string]) Stage[tracedItem, tracedItem] {
+	if t == nil {
+		return opaqueTraces(collect)
+	}
+
+	return mapOutput(func(ctx context.Context, in <-chan tracedItem, out chan<- string) error {
+		var spans []*Span
+		open := func(_ context.Context, item tracedItem) (string, error) {
+			spans = append(spans, t.newSpan(t.traceOf(item), 0, stage, map[string]string{"data": item.data}))
+			return item.data, nil
+		}
+
+		err := pumpInput(ctx, in, out, open, collect)
+		for _, span := range spans {
+			span.finish(err)
+		}
+		return err
+	}, untracedItem)
+}
+
+func untracedItem(data string) tracedItem {
+	return tracedItem{data: data}
+}
+
+func itemData(item tracedItem) string {
+	return item.data
+}
+
+// traceableItem takes the traced items of SignerJobs, strings start new traces.
+func traceableItem(item interface{}) (tracedItem, bool) {
+	if traced, ok := item.(tracedItem); ok {
+		return traced, true
+	}
+	str, ok := stringItem(item)
+	return untracedItem(str), ok
+}
+
+// startTraces runs stage on untraced items, the first traced stage starts a
+// trace for every input.
+func startTraces[Out any](stage Stage[tracedItem, Out]) Stage[string, Out] {
+	return mapInput(stage, untracedItem)
+}
+
+// dropTraces strips the traces of the results of stage.
+func dropTraces[In any](stage Stage[In, tracedItem]) Stage[In, string] {
+	return mapOutput(stage, itemData)
+}
+
+// opaqueTraces runs an untraced stage between traced ones. Its results start
+// new traces, there is no telling which input they come from.
+func opaqueTraces(stage Stage[string, string]) Stage[tracedItem, tracedItem] {
+	return mapOutput(mapInput(stage, itemData), untracedItem)
+}
+
+// untraced runs a traced stage on its own, every item starts a new trace.
+func untraced(stage Stage[tracedItem, tracedItem]) Stage[string, string] {
+	return dropTraces(startTraces(stage))
+}
+
+type tracedSigner struct {
+	Signer
+}
+
+func (s tracedSigner) Sign(ctx context.Context, data string) (string, error) {
+	ctx, finish := startSpan(ctx, s.Name(), map[string]string{"data": data})
+	hash, err := s.Signer.Sign(ctx, data)
+	finish(err)
+	return hash, err
+}
+
+func (s tracedSigner) cacheKey() string {
+	return signerCacheKey(s.Signer)
+}
+
+// Traced records a span for every call of s made within a traced item.
+func Traced(s Signer) Signer {
+	return tracedSigner{s}
+}
+
+// Spans returns a copy of the recorded spans ordered by start.
+func (t *Tracer) Spans() []Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spans := make([]Span, 0, len(t.spans))
+	for _, span := range t.spans {
+		spans = append(spans, *span)
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start.Before(spans[j].Start)
+	})
+	return spans
+}
+
+type jsonSpan struct {
+	ID       int               `json:"id"`
+	ParentID int               `json:"parent_id,omitempty"`
+	Name     string            `json:"name"`
+	Start    time.Duration     `json:"start_ns"`
+	Duration time.Duration     `json:"duration_ns"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type jsonTrace struct {
+	ID    int        `json:"trace_id"`
+	Input string     `json:"input"`
+	Spans []jsonSpan `json:"spans"`
+}
+
+// WriteJSON writes the traces as a JSON array, span times are relative to the
+// creation of the tracer.
+func (t *Tracer) WriteJSON(w io.Writer) error {
+	spans := t.Spans()
+
+	t.mu.Lock()
+	traces := make([]jsonTrace, len(t.traces))
+	for i, info := range t.traces {
+		traces[i] = jsonTrace{ID: info.id, Input: info.input, Spans: []jsonSpan{}}
+	}
+	start := t.start
+	t.mu.Unlock()
+
+	for _, span := range spans {
+		s := jsonSpan{
+			ID:       span.ID,
+			ParentID: span.ParentID,
+			Name:     span.Name,
+			Start:    span.Start.Sub(start),
+			Duration: span.End.Sub(span.Start),
+			Attrs:    span.Attrs,
+		}
+		if span.Err != nil {
+			s.Error = span.Err.Error()
+		}
+		trace := &traces[span.TraceID-1]
+		trace.Spans = append(trace.Spans, s)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(traces)
+}
+
+type chromeEvent struct {
+	Name  string            `json:"name"`
+	Cat   string            `json:"cat"`
+	Phase string            `json:"ph"`
+	TS    float64           `json:"ts"`
+	PID   int               `json:"pid"`
+	TID   int               `json:"tid"`
+	ID    int               `json:"id"`
+	Args  map[string]string `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes the spans in the Chrome trace event format, loadable
+// in chrome://tracing or Perfetto. Every trace is a track of nested async
+// events, so concurrent signer calls of one input show up side by side.
+func (t *Tracer) WriteChromeTrace(w io.Writer) error {
+	spans := t.Spans()
+	t.mu.Lock()
+	start := t.start
+	t.mu.Unlock()
+
+	micros := func(at time.Time) float64 {
+		return float64(at.Sub(start)) / float64(time.Microsecond)
+	}
+
+	events := make([]chromeEvent, 0, 2*len(spans))
+	for _, span := range spans {
+		args := span.Attrs
+		if span.Err != nil {
+			args = make(map[string]string, len(span.Attrs)+1)
+			for k, v := range span.Attrs {
+				args[k] = v
+			}
+			args["error"] = span.Err.Error()
+		}
+
+		begin := chromeEvent{Name: span.Name, Cat: "signer", Phase: "b", TS: micros(span.Start), PID: 1, TID: span.TraceID, ID: span.TraceID, Args: args}
+		end := begin
+		end.Phase, end.TS, end.Args = "e", micros(span.End), nil
+		events = append(events, begin, end)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].TS < events[j].TS
+	})
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents []chromeEvent `json:"traceEvents"`
+	}{events})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSignerPipelineTracing(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	tracer := NewTracer(clock)
+	cfg := SignerConfig{
+		Digest:        Md5Signer{},
+		Checksum:      Delayed(Crc32Signer{}, time.Second, clock),
+		DigestLimiter: NewSemaphoreWithClock(1, clock),
+		Tracer:        tracer,
+	}
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err = SignerPipeline(cfg).Collect(context.Background(), "0", "1", "1")
+	}()
+	// md5 мгновенный: все значения проходят SingleHash за секунду и MultiHash ещё за одну
+	driveClock(t, clock, clockStep{2 * 3, time.Second}, clockStep{6 * 3, time.Second})
+	<-done
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tracer.WriteJSON(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var traces []jsonTrace
+	if err := json.Unmarshal(buf.Bytes(), &traces); err != nil {
+		t.Fatalf("bad json: %v\n%s", err, buf)
+	}
+	if len(traces) != 3 {
+		t.Fatalf("expected a trace per input, got %d\n%s", len(traces), buf)
+	}
+
+	for _, trace := range traces {
+		// имя спана -> имя родителя: количество
+		got := map[string]int{}
+		names := map[int]string{}
+		for _, span := range trace.Spans {
+			names[span.ID] = span.Name
+		}
+		for _, span := range trace.Spans {
+			got[span.Name+"<"+names[span.ParentID]]++
+		}
+
+		expected := map[string]int{
+			"SingleHash<":      1,
+			"md5<SingleHash":   1,
+			"wait<md5":         1,
+			"crc32<SingleHash": 2,
+			"MultiHash<":       1,
+			"crc32<MultiHash":  6,
+			"CombineResults<":  1,
+		}
+		if len(got) != len(expected) {
+			t.Errorf("spans of input %q not match\nGot: %v\nExpected: %v", trace.Input, got, expected)
+			continue
+		}
+		for key, count := range expected {
+			if got[key] != count {
+				t.Errorf("spans of input %q not match\nGot: %v\nExpected: %v", trace.Input, got, expected)
+				break
+			}
+		}
+
+		// шесть crc32 MultiHash идут параллельно: каждый и весь MultiHash длятся секунду
+		for _, span := range trace.Spans {
+			if span.Name == "MultiHash" && span.Duration != time.Second {
+				t.Errorf("MultiHash of input %q took %s", trace.Input, span.Duration)
+			}
+		}
+	}
+
+	buf.Reset()
+	if err := tracer.WriteChromeTrace(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var chrome struct {
+		TraceEvents []chromeEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &chrome); err != nil {
+		t.Fatalf("bad chrome trace: %v", err)
+	}
+
+	open := map[int]int{}
+	for _, event := range chrome.TraceEvents {
+		switch event.Phase {
+		case "b":
+			open[event.ID]++
+		case "e":
+			open[event.ID]--
+		default:
+			t.Errorf("unexpected phase %q", event.Phase)
+		}
+	}
+	if len(chrome.TraceEvents) != 2*len(tracer.Spans()) || len(open) != 3 || open[1] != 0 || open[2] != 0 || open[3] != 0 {
+		t.Errorf("unbalanced chrome events: %v", open)
+	}
+}
+
+func TestTracingEqualValues(t *testing.T) {
+	cfg := SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}}
+	single, err := NewPipeline("SingleHash", NewSingleHashStage(cfg)).Collect(context.Background(), "0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// второй вход совпадает с результатом SingleHash первого и приходит после него,
+	// а CombineResults нет
+	tracer := NewTracer(nil)
+	cfg.Tracer = tracer
+	c := PipelineConfig{Stages: []StageConfig{{Name: stageSingleHash, Workers: 1}, {Name: stageMultiHash}}}
+	p, err := c.Build(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.Collect(context.Background(), "0", single[0]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tracer.WriteJSON(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var traces []jsonTrace
+	if err := json.Unmarshal(buf.Bytes(), &traces); err != nil {
+		t.Fatalf("bad json: %v\n%s", err, buf)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("expected a trace per input, got %d\n%s", len(traces), buf)
+	}
+
+	for _, trace := range traces {
+		// имя спана -> его вход
+		got := map[string]string{}
+		for _, span := range trace.Spans {
+			if span.ParentID == 0 {
+				got[span.Name] = span.Attrs["data"]
+			}
+		}
+		if len(got) != 2 || got["SingleHash"] != trace.Input || got["MultiHash"] == "" {
+			t.Errorf("spans of input %q not match\nGot: %v", trace.Input, got)
+		}
+		if trace.Input == "0" && got["MultiHash"] != single[0] {
+			t.Errorf("results not match\nGot: %v\nExpected: %v", got["MultiHash"], single[0])
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// equalSignatures compares in constant time, so the time taken doesn't tell how
+// much of a forged signature was right.
+func equalSignatures(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// VerifySingleHash tells whether signature is the SingleHash of data with the
+// signers of cfg.
+func VerifySingleHash(ctx context.Context, cfg SignerConfig, data, signature string) (bool, error) {
+	hash, err := cfg.signers().singleHash(ctx, data)
+	if err != nil {
+		return false, err
+	}
+	return equalSignatures(hash, signature), nil
+}
+
+// VerifyMultiHash tells whether signature is the MultiHash of data, data being
+// a SingleHash result.
+func VerifyMultiHash(ctx context.Context, cfg SignerConfig, data, signature string) (bool, error) {
+	hash, err := cfg.signers().multiHash(ctx, data)
+	if err != nil {
+		return false, err
+	}
+	return equalSignatures(hash, signature), nil
+}
+
+// CombinedVerifier checks a CombineResults value against its inputs one by one
+// and notices a wrong input as soon as it is added. It is not safe for concurrent use.
+type CombinedVerifier struct {
+	signers pipelineSigners
+	ordered bool
+	parts   []string
+	used    []bool
+	matched int
+	failed  bool
+}
+
+// NewCombinedVerifier verifies combined produced by SignerPipeline(cfg).
+// Without cfg.Ordered CombineResults sorts the parts, so unsorted ones fail
+// whatever the inputs are.
+func NewCombinedVerifier(cfg SignerConfig, combined string) *CombinedVerifier {
+	var parts []string
+	if combined != "" {
+		parts = strings.Split(combined, "_")
+	}
+
+	return &CombinedVerifier{
+		signers: cfg.signers(),
+		ordered: cfg.Ordered,
+		parts:   parts,
+		used:    make([]bool, len(parts)),
+		failed:  !cfg.Ordered && !sort.StringsAreSorted(parts),
+	}
+}
+
+// addHash matches the MultiHash of the next input with a part of combined that
+// is not matched yet: the next one in input order, any one otherwise.
+func (v *CombinedVerifier) addHash(hash string) bool {
+	if v.failed || v.matched == len(v.parts) {
+		v.failed = true
+		return false
+	}
+
+	found := -1
+	if v.ordered {
+		if equalSignatures(v.parts[v.matched], hash) {
+			found = v.matched
+		}
+	} else {
+		// every part is compared, so the time doesn't depend on where the match is
+		for i, part := range v.parts {
+			if equalSignatures(part, hash) && !v.used[i] && found < 0 {
+				found = i
+			}
+		}
+	}
+
+	if found < 0 {
+		v.failed = true
+		return false
+	}
+
+	v.used[found] = true
+	v.matched++
+	return true
+}
+
+// Add signs data and reports whether combined still may be the result of the
+// inputs added so far.
+func (v *CombinedVerifier) Add(ctx context.Context, data string) (bool, error) {
+	single, err := v.signers.singleHash(ctx, data)
+	if err != nil {
+		return false, err
+	}
+	multi, err := v.signers.multiHash(ctx, single)
+	if err != nil {
+		return false, err
+	}
+	return v.addHash(multi), nil
+}
+
+// Verified reports whether combined is exactly the result of the added inputs.
+func (v *CombinedVerifier) Verified() bool {
+	return !v.failed && v.matched == len(v.parts)
+}
+
+var errMismatch = errors.New("signature mismatch")
+
+// VerifyCombined tells whether combined is the SignerPipeline(cfg) result of
+// inputs. The inputs are signed concurrently and the check stops at the first
+// one that doesn't belong to combined.
+func VerifyCombined(ctx context.Context, cfg SignerConfig, inputs []string, combined string) (bool, error) {
+	v := NewCombinedVerifier(cfg, combined)
+
+	p := NewPipeline("SingleHash", NewSingleHashStage(cfg))
+	p = Then(p, "MultiHash", NewMultiHashStage(cfg))
+
+	in := make(chan string, len(inputs))
+	for _, input := range inputs {
+		in <- input
+	}
+	close(in)
+
+	err := p.Run(ctx, in, func(hash string) error {
+		if !v.addHash(hash) {
+			return errMismatch
+		}
+		return nil
+	})
+	if errors.Is(err, errMismatch) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return v.Verified(), nil
+}
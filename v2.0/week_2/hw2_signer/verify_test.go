@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const fibCombined = "1173136728138862632818075107442090076184424490584241521304_1696913515191343735512658979631549563179965036907783101867_27225454331033649287118297354036464389062965355426795162684_29568666068035183841425683795340791879727309630931025356555_3994492081516972096677631278379039212655368881548151736_4958044192186797981418233587017209679042592862002427381542_4958044192186797981418233587017209679042592862002427381542"
+
+func TestVerifyHashes(t *testing.T) {
+	ctx := context.Background()
+	cfg := SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}}
+
+	cases := []struct {
+		verify    func(ctx context.Context, cfg SignerConfig, data, signature string) (bool, error)
+		data      string
+		signature string
+		expected  bool
+	}{
+		{VerifySingleHash, "0", "4108050209~502633748", true},
+		{VerifySingleHash, "0", "4108050209~502633749", false},
+		{VerifySingleHash, "1", "4108050209~502633748", false},
+		{VerifyMultiHash, "4108050209~502633748", "29568666068035183841425683795340791879727309630931025356555", true},
+		{VerifyMultiHash, "4108050209~502633748", "2956866606803518384142568379534079187972730963093102535655", false},
+	}
+
+	for i, c := range cases {
+		ok, err := c.verify(ctx, cfg, c.data, c.signature)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if ok != c.expected {
+			t.Errorf("case %d: verification of %q against %q\nGot: %v\nExpected: %v", i, c.data, c.signature, ok, c.expected)
+		}
+	}
+}
+
+func TestVerifyCombined(t *testing.T) {
+	ctx := context.Background()
+	cfg := SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}}
+
+	cases := []struct {
+		inputs   []string
+		expected bool
+	}{
+		{[]string{"0", "1", "1", "2", "3", "5", "8"}, true},
+		{[]string{"8", "5", "3", "2", "1", "1", "0"}, true},
+		{[]string{"0", "1", "2", "3", "5", "8"}, false},
+		{[]string{"0", "1", "1", "1", "2", "3", "5", "8"}, false},
+		{[]string{"0", "1", "1", "2", "3", "5", "13"}, false},
+	}
+
+	for _, c := range cases {
+		ok, err := VerifyCombined(ctx, cfg, c.inputs, fibCombined)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %v", c.inputs, err)
+		}
+		if ok != c.expected {
+			t.Errorf("verification of %v\nGot: %v\nExpected: %v", c.inputs, ok, c.expected)
+		}
+	}
+}
+
+func TestVerifyCombinedUnsorted(t *testing.T) {
+	ctx := context.Background()
+	cfg := SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}}
+
+	combined, err := SignerPipeline(cfg).Collect(ctx, "0", "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// те же части в обратном порядке CombineResults не выдаёт никогда
+	parts := strings.Split(combined[0], "_")
+	swapped := parts[1] + "_" + parts[0]
+	if ok, err := VerifyCombined(ctx, cfg, []string{"0", "1"}, swapped); ok || err != nil {
+		t.Errorf("unsorted combined accepted: %v, %v", ok, err)
+	}
+	if ok, err := VerifyCombined(ctx, cfg, []string{"0", "1"}, combined[0]); !ok || err != nil {
+		t.Errorf("expected verified combined, got %v, %v", ok, err)
+	}
+}
+
+func TestCombinedVerifierIncremental(t *testing.T) {
+	ctx := context.Background()
+	cfg := SignerConfig{Digest: Md5Signer{}, Checksum: Crc32Signer{}, Ordered: true}
+
+	combined, err := SignerPipeline(cfg).Collect(ctx, "0", "1", "2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	v := NewCombinedVerifier(cfg, combined[0])
+	for _, input := range []string{"0", "1"} {
+		if ok, err := v.Add(ctx, input); !ok || err != nil {
+			t.Fatalf("input %q rejected: %v", input, err)
+		}
+	}
+	if v.Verified() {
+		t.Errorf("verified without the last input")
+	}
+
+	// порядок важен: "2" на месте "1" сразу отвергается
+	wrongOrder := NewCombinedVerifier(cfg, combined[0])
+	wrongOrder.Add(ctx, "0")
+	if ok, _ := wrongOrder.Add(ctx, "2"); ok {
+		t.Errorf("input out of order accepted")
+	}
+
+	if ok, err := v.Add(ctx, "2"); !ok || err != nil || !v.Verified() {
+		t.Errorf("expected verified combined, got %v, %v", ok, err)
+	}
+	if ok, _ := v.Add(ctx, "3"); ok || v.Verified() {
+		t.Errorf("extra input accepted")
+	}
+}